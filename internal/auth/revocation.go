@@ -0,0 +1,59 @@
+// internal/auth/revocation.go
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis keys used to revoke tokens before they expire
+
+func RevokedTokenKey(jti string) string {
+	return "revoked_token:" + jti
+}
+
+func TokenVersionKey(userID uint) string {
+	return fmt.Sprintf("token_version:%d", userID)
+}
+
+// TokenVersion returns the user's current token version. Tokens carrying
+// an older version were issued before a logout-all and are rejected.
+func TokenVersion(ctx context.Context, redisClient *redis.Client, userID uint) (int64, error) {
+	version, err := redisClient.Get(ctx, TokenVersionKey(userID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// IsRevoked checks the jti denylist and the user's token version.
+func IsRevoked(ctx context.Context, redisClient *redis.Client, claims *Claims) (bool, error) {
+	pipe := redisClient.Pipeline()
+	denied := pipe.Exists(ctx, RevokedTokenKey(claims.ID))
+	version := pipe.Get(ctx, TokenVersionKey(claims.UserID))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if denied.Val() > 0 {
+		return true, nil
+	}
+
+	current, err := version.Int64()
+	if err != nil && err != redis.Nil {
+		return false, err
+	}
+	return claims.Version < current, nil
+}
+
+// MFARequiredRolesKey holds the set of roles that must use two-factor
+// authentication, managed by admins.
+const MFARequiredRolesKey = "settings:mfa_required_roles"
+
+// MFARequired reports whether accounts with role must have passed a second
+// factor to use role-restricted routes.
+func MFARequired(ctx context.Context, redisClient *redis.Client, role string) (bool, error) {
+	return redisClient.SIsMember(ctx, MFARequiredRolesKey, role).Result()
+}
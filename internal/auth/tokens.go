@@ -0,0 +1,233 @@
+// internal/auth/tokens.go
+package auth
+
+import (
+	"consultation-booking/internal/config"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Token types carried in the typ claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// Issued after a correct password when a second factor is still needed
+	TokenTypeChallenge = "2fa_challenge"
+)
+
+const challengeTTL = 5 * time.Minute
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	UserID   uint   `json:"user_id"`
+	Role     string `json:"role,omitempty"`
+	Type     string `json:"typ"`
+	FamilyID string `json:"fid,omitempty"`
+	Version  int64  `json:"ver"`
+	MFA      bool   `json:"mfa,omitempty"` // second factor was verified at login
+	jwt.RegisteredClaims
+}
+
+// TokenManager issues and verifies JWTs. It signs with a single key and
+// verifies against every configured key, selected by the kid header, so
+// keys can be rotated without logging everybody out.
+type TokenManager struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	keyID      string
+	verifyKeys map[string]interface{}
+	issuer     string
+	audience   string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenManager(cfg config.JWTConfig) (*TokenManager, error) {
+	m := &TokenManager{
+		keyID:      cfg.KeyID,
+		verifyKeys: make(map[string]interface{}),
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		accessTTL:  cfg.AccessTTL,
+		refreshTTL: cfg.RefreshTTL,
+	}
+
+	switch cfg.Algorithm {
+	case "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		m.method = jwt.SigningMethodHS256
+		m.signingKey = []byte(cfg.Secret)
+		m.verifyKeys[cfg.KeyID] = []byte(cfg.Secret)
+
+	case "RS256":
+		pem, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT private key: %w", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT private key: %w", err)
+		}
+		m.method = jwt.SigningMethodRS256
+		m.signingKey = key
+		m.verifyKeys[cfg.KeyID] = &key.PublicKey
+
+	case "EdDSA":
+		pem, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT private key: %w", err)
+		}
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT private key: %w", err)
+		}
+		m.method = jwt.SigningMethodEdDSA
+		m.signingKey = key
+		m.verifyKeys[cfg.KeyID] = key.(ed25519.PrivateKey).Public()
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	if err := m.loadVerificationKeys(cfg.VerificationKeys); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *TokenManager) AccessTTL() time.Duration {
+	return m.accessTTL
+}
+
+func (m *TokenManager) RefreshTTL() time.Duration {
+	return m.refreshTTL
+}
+
+// Sign fills in the registered claims for the token type and signs it with
+// the current key.
+func (m *TokenManager) Sign(claims Claims) (string, error) {
+	now := time.Now()
+	ttl := m.accessTTL
+	switch claims.Type {
+	case TokenTypeRefresh:
+		ttl = m.refreshTTL
+	case TokenTypeChallenge:
+		ttl = challengeTTL
+	}
+
+	if claims.ID == "" {
+		claims.ID = NewTokenID()
+	}
+	claims.Issuer = m.issuer
+	claims.Audience = jwt.ClaimStrings{m.audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["kid"] = m.keyID
+	return token.SignedString(m.signingKey)
+}
+
+// Parse verifies the signature, expiry, issuer and audience of a token and
+// that it is of the expected type.
+func (m *TokenManager) Parse(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if !claims.VerifyIssuer(m.issuer, true) || !claims.VerifyAudience(m.audience, true) {
+		return nil, ErrInvalidToken
+	}
+	if claims.Type != tokenType || claims.ID == "" || claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// keyFunc picks the verification key by kid and makes sure the token's
+// algorithm matches the kind of key, so a public key can never be used as
+// an HMAC secret.
+func (m *TokenManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = m.keyID
+	}
+
+	key, ok := m.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	switch key.(type) {
+	case []byte:
+		_, ok = token.Method.(*jwt.SigningMethodHMAC)
+	case *rsa.PublicKey:
+		_, ok = token.Method.(*jwt.SigningMethodRSA)
+	case ed25519.PublicKey:
+		_, ok = token.Method.(*jwt.SigningMethodEd25519)
+	default:
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+	return key, nil
+}
+
+func (m *TokenManager) loadVerificationKeys(spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		kid, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || kid == "" || path == "" {
+			return fmt.Errorf("invalid JWT verification key entry %q, expected kid=path", entry)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading JWT verification key %q: %w", kid, err)
+		}
+
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			m.verifyKeys[kid] = key
+			continue
+		}
+		if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+			m.verifyKeys[kid] = key
+			continue
+		}
+		if strings.Contains(string(data), "-----BEGIN") {
+			return fmt.Errorf("unsupported JWT verification key %q", kid)
+		}
+
+		// Anything that isn't PEM is an HMAC secret
+		m.verifyKeys[kid] = []byte(strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// NewTokenID returns a random identifier for jti and token family claims.
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -3,6 +3,7 @@ package database
 
 import (
 	"log"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/postgres"
@@ -10,7 +11,10 @@ import (
 )
 
 func Connect(databaseURL string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		// Store timestamps in UTC; they are converted per reader on the way out
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,167 @@
+// internal/handlers/availability_handler.go
+package handlers
+
+import (
+	"consultation-booking/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AvailabilityHandler struct {
+	availabilityService *services.AvailabilityService
+}
+
+func NewAvailabilityHandler(availabilityService *services.AvailabilityService) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		availabilityService: availabilityService,
+	}
+}
+
+func (h *AvailabilityHandler) CreateRule(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	var req services.AvailabilityRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.availabilityService.CreateRule(expertID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *AvailabilityHandler) GetRules(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	rules, err := h.availabilityService.GetRules(expertID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *AvailabilityHandler) UpdateRule(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req services.AvailabilityRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.availabilityService.UpdateRule(expertID, uint(id), req)
+	if err != nil {
+		c.JSON(availabilityErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *AvailabilityHandler) DeleteRule(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := h.availabilityService.DeleteRule(expertID, uint(id)); err != nil {
+		c.JSON(availabilityErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability rule deleted successfully"})
+}
+
+func (h *AvailabilityHandler) CreateOverride(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	var req services.AvailabilityOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	override, err := h.availabilityService.CreateOverride(expertID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, override)
+}
+
+func (h *AvailabilityHandler) GetOverrides(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	overrides, err := h.availabilityService.GetOverrides(expertID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, overrides)
+}
+
+func (h *AvailabilityHandler) DeleteOverride(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid override ID"})
+		return
+	}
+
+	if err := h.availabilityService.DeleteOverride(expertID, uint(id)); err != nil {
+		c.JSON(availabilityErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability override deleted successfully"})
+}
+
+func availabilityErrorStatus(err error) int {
+	if errors.Is(err, services.ErrRuleNotFound) || errors.Is(err, services.ErrOverrideNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -2,26 +2,39 @@
 package handlers
 
 import (
+	"consultation-booking/internal/models"
 	"consultation-booking/internal/services"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type BookingHandler struct {
 	bookingService *services.BookingService
+	expertService  *services.ExpertService
 }
 
-func NewBookingHandler(bookingService *services.BookingService) *BookingHandler {
+func NewBookingHandler(bookingService *services.BookingService, expertService *services.ExpertService) *BookingHandler {
 	return &BookingHandler{
 		bookingService: bookingService,
+		expertService:  expertService,
 	}
 }
 
+// CreateBooking books a session. When the time is refused the response
+// suggests the expert's nearest open slots (?suggestions=, 3 by default)
+// and, with ?similar=true, other experts of the same speciality.
 func (h *BookingHandler) CreateBooking(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
 	var req services.CreateBookingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -30,10 +43,19 @@ func (h *BookingHandler) CreateBooking(c *gin.Context) {
 
 	booking, err := h.bookingService.CreateBooking(userID.(uint), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		body := bookingErrorBody(err)
+		if services.TimeConflict(err) {
+			// Point the client at the waitlist when the time is gone
+			if errors.Is(err, services.ErrSlotUnavailable) || errors.Is(err, services.ErrSlotTaken) {
+				body["waitlist_available"] = true
+			}
+			h.addSuggestions(c, body, req, loc)
+		}
+		c.JSON(bookingErrorStatus(err), body)
 		return
 	}
 
+	services.BookingInZone(booking, loc)
 	c.JSON(http.StatusCreated, booking)
 }
 
@@ -44,12 +66,18 @@ func (h *BookingHandler) GetBooking(c *gin.Context) {
 		return
 	}
 
-	booking, err := h.bookingService.GetBooking(uint(id))
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	booking, err := h.bookingService.GetBooking(uint(id), actorFromContext(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	services.BookingInZone(booking, loc)
 	c.JSON(http.StatusOK, booking)
 }
 
@@ -60,19 +88,150 @@ func (h *BookingHandler) CancelBooking(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-
 	var req struct {
 		Reason string `json:"reason"`
 	}
 	c.ShouldBindJSON(&req)
 
-	if err := h.bookingService.CancelBooking(uint(id), userID.(uint), req.Reason); err != nil {
+	booking, err := h.bookingService.CancelBooking(uint(id), actorFromContext(c), req.Reason)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), bookingErrorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Booking cancelled successfully",
+		"status":            booking.Status,
+		"late_cancellation": booking.LateCancellation,
+		"late_fee_due":      booking.LateFeeDue,
+	})
+}
+
+func (h *BookingHandler) RescheduleBooking(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.RescheduleBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled successfully"})
+	reschedule, err := h.bookingService.RescheduleBooking(uint(id), actorFromContext(c), req)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), bookingErrorBody(err))
+		return
+	}
+
+	// Requests waiting for the other party's approval
+	status := http.StatusOK
+	if reschedule.Status == models.RescheduleStatusPending {
+		status = http.StatusAccepted
+	}
+
+	services.RescheduleInZone(reschedule, loc)
+	c.JSON(status, reschedule)
+}
+
+func (h *BookingHandler) ApproveReschedule(c *gin.Context) {
+	h.decideReschedule(c, h.bookingService.ApproveReschedule)
+}
+
+func (h *BookingHandler) DeclineReschedule(c *gin.Context) {
+	h.decideReschedule(c, h.bookingService.DeclineReschedule)
+}
+
+func (h *BookingHandler) decideReschedule(c *gin.Context, decide func(uint, services.Actor) (*models.BookingReschedule, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	reschedule, err := decide(uint(id), actorFromContext(c))
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RescheduleInZone(reschedule, loc)
+	c.JSON(http.StatusOK, reschedule)
+}
+
+func (h *BookingHandler) GetReschedules(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	reschedules, err := h.bookingService.GetReschedules(uint(id), actorFromContext(c))
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range reschedules {
+		services.RescheduleInZone(&reschedules[i], loc)
+	}
+	c.JSON(http.StatusOK, reschedules)
+}
+
+func (h *BookingHandler) HoldSlot(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot ID"})
+		return
+	}
+
+	hold, err := h.bookingService.HoldSlot(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrSlotUnavailable) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSlotTaken) || errors.Is(err, services.ErrSlotHeld) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (h *BookingHandler) ReleaseHold(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot ID"})
+		return
+	}
+
+	if err := h.bookingService.ReleaseHold(c.GetUint("user_id"), uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Slot hold released"})
 }
 
 func (h *BookingHandler) GetAllBookings(c *gin.Context) {
@@ -84,3 +243,24 @@ func (h *BookingHandler) GetStats(c *gin.Context) {
 	// Admin endpoint to get booking statistics
 	c.JSON(http.StatusOK, gin.H{"message": "Admin stats endpoint"})
 }
+
+// addSuggestions adds other times to try to a refused booking's error.
+// Failing to find any doesn't change the error.
+func (h *BookingHandler) addSuggestions(c *gin.Context, body gin.H, req services.CreateBookingRequest, loc *time.Location) {
+	n := suggestionCount(c.Query("suggestions"), services.DefaultSuggestions)
+
+	if slots, err := h.expertService.SuggestSlots(req.ExpertID, req.SessionTypeID, req.StartTime, n); err == nil {
+		services.SlotsInZone(slots, loc)
+		body["suggestions"] = slots
+	}
+
+	if similar, _ := strconv.ParseBool(c.Query("similar")); similar {
+		if experts, err := h.expertService.SimilarExperts(req.ExpertID, req.StartTime, n); err == nil {
+			for i := range experts {
+				experts[i].FirstAvailable = experts[i].FirstAvailable.In(loc)
+				services.SlotsInZone(experts[i].Slots, loc)
+			}
+			body["similar_experts"] = experts
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// internal/handlers/booking_series_handler.go
+package handlers
+
+import (
+	"consultation-booking/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *BookingHandler) CreateSeries(c *gin.Context) {
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.bookingService.CreateSeries(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), bookingErrorBody(err))
+		return
+	}
+
+	services.BookingsInZone(result.Series.Bookings, loc)
+	c.JSON(http.StatusCreated, result)
+}
+
+func (h *BookingHandler) GetSeries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	series, err := h.bookingService.GetSeries(uint(id), actorFromContext(c))
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	services.BookingsInZone(series.Bookings, loc)
+	c.JSON(http.StatusOK, series)
+}
+
+func (h *BookingHandler) CancelSeries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.CancelSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookings, err := h.bookingService.CancelSeries(uint(id), actorFromContext(c), req)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), bookingErrorBody(err))
+		return
+	}
+
+	services.BookingsInZone(bookings, loc)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Bookings cancelled successfully",
+		"bookings": bookings,
+	})
+}
+
+func (h *BookingHandler) RescheduleSeries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.RescheduleSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reschedules, err := h.bookingService.RescheduleSeries(uint(id), actorFromContext(c), req)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), bookingErrorBody(err))
+		return
+	}
+
+	for i := range reschedules {
+		services.RescheduleInZone(&reschedules[i], loc)
+	}
+	c.JSON(http.StatusOK, reschedules)
+}
@@ -0,0 +1,100 @@
+// internal/handlers/context.go
+package handlers
+
+import (
+	"consultation-booking/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// actorFromContext builds the service actor from the claims set by
+// middleware.AuthMiddleware.
+func actorFromContext(c *gin.Context) services.Actor {
+	return services.Actor{
+		UserID: c.GetUint("user_id"),
+		Role:   c.GetString("role"),
+	}
+}
+
+// responseLocation returns the zone requested with ?tz= for rendering
+// times in the response, defaulting to UTC. It writes a 400 for unknown
+// zones.
+func responseLocation(c *gin.Context) (*time.Location, bool) {
+	loc, err := services.LoadTimezone(c.Query("tz"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+		return nil, false
+	}
+	return loc, true
+}
+
+// bookingErrorStatus maps booking service errors to HTTP status codes.
+func bookingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBookingNotFound), errors.Is(err, services.ErrExpertNotFound), errors.Is(err, services.ErrSeriesNotFound),
+		errors.Is(err, services.ErrSessionTypeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSlotTaken), errors.Is(err, services.ErrSlotHeld), errors.Is(err, services.ErrUserConflict), errors.Is(err, services.ErrExpertConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrForbidden), errors.Is(err, services.ErrTransitionNotPermitted), errors.Is(err, services.ErrEmailNotVerified),
+		errors.Is(err, services.ErrCancellationLimitReached):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrIllegalTransition), errors.Is(err, services.ErrNotReschedulable), errors.Is(err, services.ErrReschedulePending),
+		errors.Is(err, services.ErrCancellationTooLate), errors.Is(err, services.ErrBufferConflict), errors.Is(err, services.ErrDailyLimitReached),
+		errors.Is(err, services.ErrSeriesShiftOverlaps):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrNoPendingReschedule), errors.Is(err, services.ErrNoOccurrencesToChange):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// bookingErrorBody renders a booking service error, adding the code of
+// policy and scheduling refusals and the series occurrence that failed.
+func bookingErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+
+	var cancellationErr *services.CancellationError
+	if errors.As(err, &cancellationErr) {
+		body["code"] = cancellationErr.Code
+	}
+
+	var schedulingErr *services.SchedulingError
+	if errors.As(err, &schedulingErr) {
+		body["code"] = schedulingErr.Code
+	}
+
+	var occurrenceErr *services.SeriesOccurrenceError
+	if errors.As(err, &occurrenceErr) {
+		body["occurrence"] = occurrenceErr.Index
+	}
+	return body
+}
+
+// suggestionCount parses how many slots to suggest, falling back to def
+// and capped at services.MaxSuggestions.
+func suggestionCount(value string, def int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return def
+	}
+	if n > services.MaxSuggestions {
+		return services.MaxSuggestions
+	}
+	return n
+}
+
+// currentExpertID returns the expert profile set by
+// middleware.ExpertMiddleware, writing a 403 when there is none.
+func currentExpertID(c *gin.Context) (uint, bool) {
+	expertID := c.GetUint("expert_id")
+	if expertID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Expert profile not found"})
+		return 0, false
+	}
+	return expertID, true
+}
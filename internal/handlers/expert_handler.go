@@ -3,19 +3,23 @@ package handlers
 
 import (
 	"consultation-booking/internal/services"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ExpertHandler struct {
-	expertService *services.ExpertService
+	expertService  *services.ExpertService
+	bookingService *services.BookingService
 }
 
-func NewExpertHandler(expertService *services.ExpertService) *ExpertHandler {
+func NewExpertHandler(expertService *services.ExpertService, bookingService *services.BookingService) *ExpertHandler {
 	return &ExpertHandler{
-		expertService: expertService,
+		expertService:  expertService,
+		bookingService: bookingService,
 	}
 }
 
@@ -39,14 +43,59 @@ func (h *ExpertHandler) CreateExpert(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "Expert created successfully"})
 }
 
+// GetExperts pages through the expert directory. Filters, sort and the
+// cursor of the next page are query parameters.
 func (h *ExpertHandler) GetExperts(c *gin.Context) {
-	experts, err := h.expertService.GetExperts()
+	var req services.ExpertDirectoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.expertService.GetExperts(req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidDirectorySort) || errors.Is(err, services.ErrInvalidFormat) ||
+			errors.Is(err, services.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SearchAvailability finds experts free within a time range, filtered by
+// speciality, format, language and price.
+func (h *ExpertHandler) SearchAvailability(c *gin.Context) {
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.SlotSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, experts)
+	results, err := h.expertService.SearchAvailability(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidSearchRange) || errors.Is(err, services.ErrInvalidFormat) ||
+			errors.Is(err, services.ErrInvalidSearchSort) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range results {
+		results[i].FirstAvailable = results[i].FirstAvailable.In(loc)
+		services.SlotsInZone(results[i].Slots, loc)
+	}
+	c.JSON(http.StatusOK, results)
 }
 
 func (h *ExpertHandler) GetExpertByID(c *gin.Context) {
@@ -65,14 +114,59 @@ func (h *ExpertHandler) GetExpertByID(c *gin.Context) {
 	c.JSON(http.StatusOK, expert)
 }
 
-func (h *ExpertHandler) CreateAvailableSlot(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+// GetNextAvailable lists the expert's next bookable slots after ?after=
+// (RFC 3339, now by default), ?limit= of them. With ?session_type_id= only
+// slots a session of that type fits in are listed.
+func (h *ExpertHandler) GetNextAvailable(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expert ID"})
+		return
+	}
 
-	// Get expert ID from user ID
-	var expertID uint
-	// This would typically be done through a service method
-	// For now, we'll assume userID is the expertID
-	expertID = userID.(uint)
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	after := time.Now()
+	if v := c.Query("after"); v != "" {
+		if after, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after time"})
+			return
+		}
+	}
+
+	var sessionTypeID *uint
+	if v := c.Query("session_type_id"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session type ID"})
+			return
+		}
+		typeID := uint(parsed)
+		sessionTypeID = &typeID
+	}
+
+	slots, err := h.expertService.NextAvailable(uint(id), sessionTypeID, after, suggestionCount(c.Query("limit"), services.DefaultSuggestions))
+	if err != nil {
+		if errors.Is(err, services.ErrSessionTypeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.SlotsInZone(slots, loc)
+	c.JSON(http.StatusOK, slots)
+}
+
+func (h *ExpertHandler) CreateAvailableSlot(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
 
 	var req services.CreateSlotRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -81,7 +175,7 @@ func (h *ExpertHandler) CreateAvailableSlot(c *gin.Context) {
 	}
 
 	if err := h.expertService.CreateAvailableSlot(expertID, req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(slotErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -95,22 +189,31 @@ func (h *ExpertHandler) GetAvailableSlots(c *gin.Context) {
 		return
 	}
 
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
 	slots, err := h.expertService.GetAvailableSlots(uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	services.SlotsInZone(slots, loc)
 	c.JSON(http.StatusOK, slots)
 }
 
 func (h *ExpertHandler) GetExpertBookings(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
 
-	// Get expert ID from user ID
-	var expertID uint
-	// This would typically be done through a service method
-	expertID = userID.(uint)
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
 
 	bookings, err := h.expertService.GetExpertBookings(expertID)
 	if err != nil {
@@ -118,6 +221,7 @@ func (h *ExpertHandler) GetExpertBookings(c *gin.Context) {
 		return
 	}
 
+	services.BookingsInZone(bookings, loc)
 	c.JSON(http.StatusOK, bookings)
 }
 
@@ -130,17 +234,287 @@ func (h *ExpertHandler) UpdateBookingStatus(c *gin.Context) {
 
 	var req struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	booking, err := h.bookingService.TransitionBooking(uint(id), actorFromContext(c), req.Status, req.Reason)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), bookingErrorBody(err))
+		return
 	}
 
+	services.BookingInZone(booking, loc)
+	c.JSON(http.StatusOK, booking)
+}
+
+func (h *ExpertHandler) UpdateProfile(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ExpertProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.expertService.UpdateBookingStatus(uint(id), req.Status); err != nil {
+	if err := h.expertService.UpdateProfile(expertID, req); err != nil {
+		if errors.Is(err, services.ErrInvalidTimezone) || errors.Is(err, services.ErrInvalidLanguage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Expert profile updated successfully"})
+}
+
+func (h *ExpertHandler) GetCancellationPolicy(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	policy, err := h.expertService.GetCancellationPolicy(expertID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Booking status updated successfully"})
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *ExpertHandler) UpdateCancellationPolicy(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	var req services.CancellationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.expertService.UpdateCancellationPolicy(expertID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCancellationPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *ExpertHandler) ResetCancellationPolicy(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.expertService.ResetCancellationPolicy(expertID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cancellation policy reset to the platform default"})
+}
+
+func (h *ExpertHandler) GetSettings(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	settings, err := h.expertService.GetSettings(expertID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *ExpertHandler) UpdateSettings(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ExpertSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.expertService.UpdateSettings(expertID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *ExpertHandler) ResetSettings(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.expertService.ResetSettings(expertID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Settings reset to the platform defaults"})
+}
+
+// GetExpertSessionTypes lists the session types a client can book with an
+// expert.
+func (h *ExpertHandler) GetExpertSessionTypes(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expert ID"})
+		return
+	}
+
+	sessionTypes, err := h.expertService.GetSessionTypes(uint(id), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionTypes)
+}
+
+func (h *ExpertHandler) GetSessionTypes(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	sessionTypes, err := h.expertService.GetSessionTypes(expertID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionTypes)
+}
+
+func (h *ExpertHandler) CreateSessionType(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	var req services.SessionTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionType, err := h.expertService.CreateSessionType(expertID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sessionType)
+}
+
+func (h *ExpertHandler) UpdateSessionType(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session type ID"})
+		return
+	}
+
+	var req services.SessionTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionType, err := h.expertService.UpdateSessionType(expertID, uint(id), req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSessionTypeNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionType)
+}
+
+func (h *ExpertHandler) DeleteSessionType(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session type ID"})
+		return
+	}
+
+	if err := h.expertService.DeleteSessionType(expertID, uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSessionTypeNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session type deleted"})
+}
+
+func (h *ExpertHandler) GetSlotAttendees(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	slotID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot ID"})
+		return
+	}
+
+	attendees, err := h.expertService.GetSlotAttendees(expertID, uint(slotID))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSlotNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attendees)
 }
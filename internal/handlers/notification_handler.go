@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"consultation-booking/internal/services"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -45,8 +46,15 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.notificationService.MarkAsRead(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.notificationService.MarkAsRead(uint(id), actorFromContext(c)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotificationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
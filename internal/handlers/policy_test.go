@@ -0,0 +1,160 @@
+// internal/handlers/policy_test.go
+package handlers
+
+import (
+	"consultation-booking/internal/config"
+	"consultation-booking/internal/models"
+	"consultation-booking/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Expert{},
+		&models.Booking{},
+		&models.Notification{},
+		&models.AvailableSlot{},
+		&models.ExpertSettings{},
+	); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	return db
+}
+
+// TestOwnershipStatusCodes checks that the handlers map the services'
+// ownership errors to 403 and missing resources to 404.
+func TestOwnershipStatusCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	bookingService := services.NewBookingService(db, rdb, config.BookingConfig{})
+	notificationService := services.NewNotificationService(db, rdb)
+	expertService := services.NewExpertService(db, rdb, config.BookingConfig{})
+
+	users := []models.User{
+		{Email: "client@example.com", Role: "user"},
+		{Email: "other@example.com", Role: "user"},
+		{Email: "expert@example.com", Role: "expert"},
+		{Email: "other-expert@example.com", Role: "expert"},
+	}
+	for i := range users {
+		users[i].Password = "x"
+		users[i].IsActive = true
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+	}
+	client, otherClient, expertUser, otherExpertUser := users[0], users[1], users[2], users[3]
+
+	expert := models.Expert{UserID: expertUser.ID, IsAvailable: true}
+	otherExpert := models.Expert{UserID: otherExpertUser.ID, IsAvailable: true}
+	for _, e := range []*models.Expert{&expert, &otherExpert} {
+		if err := db.Create(e).Error; err != nil {
+			t.Fatalf("creating expert: %v", err)
+		}
+	}
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour).UTC()
+	slot := models.AvailableSlot{ExpertID: expert.ID, StartTime: start, EndTime: start.Add(time.Hour)}
+	if err := db.Create(&slot).Error; err != nil {
+		t.Fatalf("creating slot: %v", err)
+	}
+	booking, err := bookingService.CreateBooking(client.ID, services.CreateBookingRequest{
+		ExpertID:  expert.ID,
+		StartTime: slot.StartTime,
+		EndTime:   slot.EndTime,
+	})
+	if err != nil {
+		t.Fatalf("booking slot: %v", err)
+	}
+
+	notification := models.Notification{UserID: client.ID, Title: "Booking", Message: "Booked"}
+	if err := db.Create(&notification).Error; err != nil {
+		t.Fatalf("creating notification: %v", err)
+	}
+
+	bookingHandler := NewBookingHandler(bookingService, expertService)
+	notificationHandler := NewNotificationHandler(notificationService)
+	expertHandler := NewExpertHandler(expertService, bookingService)
+
+	bookingPath := "/bookings/" + itoa(booking.ID)
+	notificationPath := "/notifications/" + itoa(notification.ID) + "/read"
+	statusPath := "/expert/bookings/" + itoa(booking.ID) + "/status"
+	confirm := `{"status":"confirmed"}`
+
+	// Run in order: the expert confirms the booking only after the
+	// rejected attempts.
+	tests := []struct {
+		name       string
+		user       models.User
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"view as client", client, http.MethodGet, bookingPath, "", http.StatusOK},
+		{"view as other client", otherClient, http.MethodGet, bookingPath, "", http.StatusForbidden},
+		{"view as other expert", otherExpertUser, http.MethodGet, bookingPath, "", http.StatusForbidden},
+		{"view missing", client, http.MethodGet, "/bookings/999999", "", http.StatusNotFound},
+
+		{"mark read as expert", expertUser, http.MethodPut, notificationPath, "", http.StatusForbidden},
+		{"mark read as other client", otherClient, http.MethodPut, notificationPath, "", http.StatusForbidden},
+		{"mark read missing", client, http.MethodPut, "/notifications/999999/read", "", http.StatusNotFound},
+		{"mark read as recipient", client, http.MethodPut, notificationPath, "", http.StatusOK},
+
+		{"status as client", client, http.MethodPut, statusPath, confirm, http.StatusForbidden},
+		{"status as other expert", otherExpertUser, http.MethodPut, statusPath, confirm, http.StatusForbidden},
+		{"status missing", expertUser, http.MethodPut, "/expert/bookings/999999/status", confirm, http.StatusNotFound},
+		{"status as expert", expertUser, http.MethodPut, statusPath, confirm, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Set("user_id", tt.user.ID)
+				c.Set("role", tt.user.Role)
+			})
+			router.GET("/bookings/:id", bookingHandler.GetBooking)
+			router.PUT("/notifications/:id/read", notificationHandler.MarkAsRead)
+			router.PUT("/expert/bookings/:id/status", expertHandler.UpdateBookingStatus)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
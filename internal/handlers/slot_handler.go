@@ -0,0 +1,208 @@
+// internal/handlers/slot_handler.go
+package handlers
+
+import (
+	"consultation-booking/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetExpertSlots lists the expert's own slots, booked ones included.
+// ?from= and ?to= take RFC 3339 times; from defaults to now.
+func (h *ExpertHandler) GetExpertSlots(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	from := time.Now()
+	var to time.Time
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time"})
+			return
+		}
+	}
+
+	slots, err := h.expertService.GetExpertSlots(expertID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.SlotsInZone(slots, loc)
+	c.JSON(http.StatusOK, slots)
+}
+
+// BulkCreateSlots creates many slots at once from a JSON list under
+// "slots", a text/csv body or a CSV file uploaded as "file".
+func (h *ExpertHandler) BulkCreateSlots(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var reqs []services.CreateSlotRequest
+	var err error
+	switch c.ContentType() {
+	case "text/csv":
+		reqs, err = services.ParseSlotsCSV(c.Request.Body)
+	case "multipart/form-data":
+		file, ferr := c.FormFile("file")
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
+			return
+		}
+		f, ferr := file.Open()
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ferr.Error()})
+			return
+		}
+		defer f.Close()
+		reqs, err = services.ParseSlotsCSV(f)
+	default:
+		var req struct {
+			Slots []services.CreateSlotRequest `json:"slots" binding:"required,dive"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reqs = req.Slots
+	}
+	if err != nil {
+		c.JSON(slotErrorStatus(err), slotErrorBody(err))
+		return
+	}
+
+	slots, err := h.expertService.BulkCreateSlots(expertID, reqs)
+	if err != nil {
+		c.JSON(slotErrorStatus(err), slotErrorBody(err))
+		return
+	}
+
+	services.SlotsInZone(slots, loc)
+	c.JSON(http.StatusCreated, slots)
+}
+
+func (h *ExpertHandler) UpdateSlot(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	slotID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateSlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slot, err := h.expertService.UpdateSlot(expertID, uint(slotID), req)
+	if err != nil {
+		c.JSON(slotErrorStatus(err), slotErrorBody(err))
+		return
+	}
+
+	slot.StartTime = slot.StartTime.In(loc)
+	slot.EndTime = slot.EndTime.In(loc)
+	c.JSON(http.StatusOK, slot)
+}
+
+// DeleteSlot removes a slot. Slots with active bookings are refused unless
+// ?cancel_bookings=true, which cancels them and notifies the clients with
+// the optional ?reason=.
+func (h *ExpertHandler) DeleteSlot(c *gin.Context) {
+	expertID, ok := currentExpertID(c)
+	if !ok {
+		return
+	}
+
+	slotID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	cancelBookings, _ := strconv.ParseBool(c.Query("cancel_bookings"))
+	reason := c.DefaultQuery("reason", "The expert removed this time slot")
+
+	cancelled, err := h.bookingService.DeleteSlot(expertID, uint(slotID), actorFromContext(c), cancelBookings, reason)
+	if err != nil {
+		c.JSON(slotErrorStatus(err), slotErrorBody(err))
+		return
+	}
+
+	services.BookingsInZone(cancelled, loc)
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Slot deleted successfully",
+		"cancelled_bookings": cancelled,
+	})
+}
+
+// slotErrorStatus maps slot management errors to HTTP status codes. Errors
+// from cancelling the bookings of a deleted slot map as booking errors.
+func slotErrorStatus(err error) int {
+	var bulkErr *services.BulkSlotError
+	switch {
+	case errors.As(err, &bulkErr), errors.Is(err, services.ErrSlotEndBeforeStart), errors.Is(err, services.ErrSlotInPast),
+		errors.Is(err, services.ErrNoSlots), errors.Is(err, services.ErrTooManySlots):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrSlotNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSlotOverlap), errors.Is(err, services.ErrSlotHasBookings), errors.Is(err, services.ErrGeneratedSlot),
+		errors.Is(err, services.ErrCapacityBelowBooked), errors.Is(err, services.ErrCancellationTooLate):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrForbidden), errors.Is(err, services.ErrTransitionNotPermitted):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// slotErrorBody renders a slot management error with the rejected rows of
+// a bulk upload.
+func slotErrorBody(err error) gin.H {
+	body := bookingErrorBody(err)
+
+	var bulkErr *services.BulkSlotError
+	if errors.As(err, &bulkErr) {
+		body["rows"] = bulkErr.Rows
+	}
+	return body
+}
@@ -3,9 +3,13 @@ package handlers
 
 import (
 	"consultation-booking/internal/services"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
@@ -41,18 +45,156 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.Login(req)
+	response, challenge, err := h.userService.Login(req)
 	if err != nil {
+		if errors.Is(err, services.ErrAccountDeactivated) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
 func (h *UserHandler) RefreshToken(c *gin.Context) {
-	// Implementation for refresh token
-	c.JSON(http.StatusOK, gin.H{"message": "Refresh token endpoint"})
+	var req services.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.RefreshToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req services.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same answer whether or not the account exists
+	h.userService.ForgotPassword(req)
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req services.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.ResetPassword(req); err != nil {
+		if errors.Is(err, services.ErrInvalidOneTimeToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req services.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.VerifyEmail(req); err != nil {
+		if errors.Is(err, services.ErrInvalidOneTimeToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	err := h.userService.ResendVerification(c.GetUint("user_id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrResendTooSoon):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *UserHandler) Logout(c *gin.Context) {
+	expiresAt, _ := c.Get("token_expires_at")
+	exp, _ := expiresAt.(time.Time)
+
+	if err := h.userService.Logout(c.GetUint("user_id"), c.GetString("jti"), c.GetString("family_id"), exp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	if err := h.userService.LogoutAll(c.GetUint("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+func (h *UserHandler) SetUserActive(c *gin.Context) {
+	// Admin endpoint to activate or deactivate an account
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		IsActive *bool `json:"is_active" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.SetActive(uint(id), *req.IsActive); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated successfully"})
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
@@ -69,18 +211,17 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
+	var req services.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Remove sensitive fields
-	delete(updates, "password")
-	delete(updates, "role")
-	delete(updates, "id")
-
-	if err := h.userService.UpdateProfile(userID.(uint), updates); err != nil {
+	if err := h.userService.UpdateProfile(userID.(uint), req); err != nil {
+		if errors.Is(err, services.ErrInvalidTimezone) || errors.Is(err, services.ErrUserExists) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -90,11 +231,132 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 func (h *UserHandler) GetBookingHistory(c *gin.Context) {
 	userID, _ := c.Get("user_id")
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
 	bookings, err := h.userService.GetBookingHistory(userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	services.BookingsInZone(bookings, loc)
 	c.JSON(http.StatusOK, bookings)
 }
+
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var req services.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.LoginTwoFactor(req)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	enrollment, err := h.userService.EnrollTwoFactor(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *UserHandler) VerifyTwoFactor(c *gin.Context) {
+	var req services.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	activation, err := h.userService.VerifyTwoFactor(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, activation)
+}
+
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	var req services.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.DisableTwoFactor(c.GetUint("user_id"), req); err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req services.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.userService.RegenerateRecoveryCodes(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *UserHandler) GetTwoFactorPolicy(c *gin.Context) {
+	// Admin endpoint listing roles that must use two-factor authentication
+	roles, err := h.userService.GetMFARequiredRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"required_roles": roles})
+}
+
+func (h *UserHandler) UpdateTwoFactorPolicy(c *gin.Context) {
+	var req struct {
+		RequiredRoles []string `json:"required_roles"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.SetMFARequiredRoles(req.RequiredRoles); err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"required_roles": req.RequiredRoles})
+}
+
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidChallenge), errors.Is(err, services.ErrInvalidTwoFactorCode):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrNoPendingEnrollment), errors.Is(err, services.ErrInvalidRole):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
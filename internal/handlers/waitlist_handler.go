@@ -0,0 +1,132 @@
+// internal/handlers/waitlist_handler.go
+package handlers
+
+import (
+	"consultation-booking/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WaitlistHandler struct {
+	waitlistService *services.WaitlistService
+}
+
+func NewWaitlistHandler(waitlistService *services.WaitlistService) *WaitlistHandler {
+	return &WaitlistHandler{
+		waitlistService: waitlistService,
+	}
+}
+
+func (h *WaitlistHandler) JoinWaitlist(c *gin.Context) {
+	var req services.JoinWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.waitlistService.JoinWaitlist(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *WaitlistHandler) GetEntries(c *gin.Context) {
+	entries, err := h.waitlistService.GetEntries(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *WaitlistHandler) LeaveWaitlist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waitlist entry ID"})
+		return
+	}
+
+	if err := h.waitlistService.LeaveWaitlist(uint(id), c.GetUint("user_id")); err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left the waitlist"})
+}
+
+func (h *WaitlistHandler) GetOffers(c *gin.Context) {
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	offers, err := h.waitlistService.GetOffers(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range offers {
+		offers[i].ExpiresAt = offers[i].ExpiresAt.In(loc)
+		offers[i].Slot.StartTime = offers[i].Slot.StartTime.In(loc)
+		offers[i].Slot.EndTime = offers[i].Slot.EndTime.In(loc)
+	}
+	c.JSON(http.StatusOK, offers)
+}
+
+func (h *WaitlistHandler) ClaimOffer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	loc, ok := responseLocation(c)
+	if !ok {
+		return
+	}
+
+	var req services.ClaimOfferRequest
+	c.ShouldBindJSON(&req)
+
+	booking, err := h.waitlistService.ClaimOffer(uint(id), c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	services.BookingInZone(booking, loc)
+	c.JSON(http.StatusCreated, booking)
+}
+
+func (h *WaitlistHandler) DeclineOffer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	if err := h.waitlistService.DeclineOffer(uint(id), c.GetUint("user_id")); err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Offer declined"})
+}
+
+func waitlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrWaitlistEntryNotFound), errors.Is(err, services.ErrOfferNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAlreadyWaitlisted), errors.Is(err, services.ErrOfferExpired):
+		return http.StatusConflict
+	}
+	return bookingErrorStatus(err)
+}
@@ -2,7 +2,10 @@
 package middleware
 
 import (
+	"consultation-booking/internal/auth"
+	"consultation-booking/internal/services"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,16 +13,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
-	jwt.RegisteredClaims
-}
-
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// AuthMiddleware validates the bearer access token and rejects tokens
+// revoked by logout (jti denylist) or logout-all/deactivation (per-user
+// token version).
+func AuthMiddleware(tokens *auth.TokenManager, redisClient *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -29,20 +28,46 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		claims := &Claims{}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		// Refresh tokens are only accepted by /auth/refresh
+		claims, err := tokens.Parse(tokenString, auth.TokenTypeAccess)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := auth.IsRevoked(c.Request.Context(), redisClient, claims)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// RoleMiddleware turns away roles that must use a second factor
+		// when this session didn't pass one
+		if !claims.MFA {
+			required, err := auth.MFARequired(c.Request.Context(), redisClient, claims.Role)
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+				c.Abort()
+				return
+			}
+			c.Set("mfa_missing", required)
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("jti", claims.ID)
+		c.Set("family_id", claims.FamilyID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
 		c.Next()
 	}
 }
@@ -59,6 +84,11 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 		userRole := role.(string)
 		for _, allowedRole := range allowedRoles {
 			if userRole == allowedRole {
+				if c.GetBool("mfa_missing") {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for this role"})
+					c.Abort()
+					return
+				}
 				c.Next()
 				return
 			}
@@ -69,6 +99,32 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	}
 }
 
+// ExpertMiddleware resolves the expert profile of the authenticated user
+// and exposes it to handlers as expert_id. Users without one are refused,
+// except admins, who pass without expert_id and are turned away only by
+// handlers that manage a profile.
+func ExpertMiddleware(expertService *services.ExpertService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expertID, err := expertService.ExpertIDForUser(c.GetUint("user_id"))
+		if err != nil {
+			if errors.Is(err, services.ErrNoExpertProfile) {
+				if c.GetString("role") == "admin" {
+					c.Next()
+					return
+				}
+				c.JSON(http.StatusForbidden, gin.H{"error": "Expert profile not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("expert_id", expertID)
+		c.Next()
+	}
+}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
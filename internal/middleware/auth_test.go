@@ -0,0 +1,101 @@
+// internal/middleware/auth_test.go
+package middleware
+
+import (
+	"consultation-booking/internal/config"
+	"consultation-booking/internal/models"
+	"consultation-booking/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newExpertService(t *testing.T) (*services.ExpertService, *gorm.DB) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.User{}, &models.Expert{}); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return services.NewExpertService(db, rdb, config.BookingConfig{}), db
+}
+
+func TestExpertMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	expertService, db := newExpertService(t)
+
+	// Users are created first so no user ID matches the expert ID
+	users := []models.User{
+		{Email: "client@example.com", Role: "user"},
+		{Email: "expert@example.com", Role: "expert"},
+		{Email: "admin@example.com", Role: "admin"},
+	}
+	for i := range users {
+		users[i].Password = "x"
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+	}
+	client, expertUser, admin := users[0], users[1], users[2]
+
+	expert := models.Expert{UserID: expertUser.ID}
+	if err := db.Create(&expert).Error; err != nil {
+		t.Fatalf("creating expert: %v", err)
+	}
+	if expert.ID == expertUser.ID {
+		t.Fatalf("expert %d shares its ID with its user", expert.ID)
+	}
+
+	tests := []struct {
+		name         string
+		user         models.User
+		wantStatus   int
+		wantExpertID uint
+	}{
+		{"expert", expertUser, http.StatusOK, expert.ID},
+		{"user without profile", client, http.StatusForbidden, 0},
+		{"admin without profile", admin, http.StatusOK, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotExpertID uint
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Set("user_id", tt.user.ID)
+				c.Set("role", tt.user.Role)
+			})
+			router.GET("/expert", ExpertMiddleware(expertService), func(c *gin.Context) {
+				gotExpertID = c.GetUint("expert_id")
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/expert", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotExpertID != tt.wantExpertID {
+				t.Fatalf("expert_id %d, want %d", gotExpertID, tt.wantExpertID)
+			}
+		})
+	}
+}
@@ -8,19 +8,24 @@ import (
 )
 
 type User struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Email       string         `json:"email" gorm:"unique;not null"`
-	Password    string         `json:"-" gorm:"not null"`
-	Name        string         `json:"name"`
-	Phone       string         `json:"phone"`
-	Avatar      string         `json:"avatar"`
-	Description string         `json:"description"`
-	Gender      string         `json:"gender"`
-	Role        string         `json:"role" gorm:"default:user"` // user, expert, admin
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	Email            string         `json:"email" gorm:"unique;not null"`
+	Password         string         `json:"-" gorm:"not null"`
+	EmailVerified    bool           `json:"email_verified" gorm:"default:false"`
+	EmailVerifiedAt  *time.Time     `json:"email_verified_at"`
+	TwoFactorEnabled bool           `json:"two_factor_enabled" gorm:"default:false"`
+	TOTPSecret       string         `json:"-"`
+	Name             string         `json:"name"`
+	Phone            string         `json:"phone"`
+	Avatar           string         `json:"avatar"`
+	Description      string         `json:"description"`
+	Gender           string         `json:"gender"`
+	Timezone         string         `json:"timezone" gorm:"default:UTC"` // IANA name, e.g. Asia/Ho_Chi_Minh
+	Role             string         `json:"role" gorm:"default:user"`    // user, expert, admin
+	IsActive         bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
 	Bookings      []Booking      `json:"bookings,omitempty"`
@@ -28,52 +33,308 @@ type User struct {
 }
 
 type Expert struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	UserID      uint           `json:"user_id" gorm:"not null"`
-	User        User           `json:"user"`
-	Speciality  string         `json:"speciality"`
-	Experience  int            `json:"experience"`
-	Rating      float64        `json:"rating" gorm:"default:0"`
-	IsAvailable bool           `json:"is_available" gorm:"default:true"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	UserID         uint           `json:"user_id" gorm:"not null"`
+	User           User           `json:"user"`
+	Speciality     string         `json:"speciality" gorm:"index:idx_experts_speciality,expression:lower(speciality)"`
+	Experience     int            `json:"experience"`
+	Rating         float64        `json:"rating" gorm:"default:0;index"`
+	IsAvailable    bool           `json:"is_available" gorm:"default:true"`
+	OffersOnline   bool           `json:"offers_online" gorm:"default:true"`                // holds online sessions
+	OffersOffline  bool           `json:"offers_offline" gorm:"default:false"`              // holds in-person sessions
+	Timezone       string         `json:"timezone" gorm:"default:UTC"`                      // zone availability rules are written in
+	FromPriceCents *int64         `json:"from_price_cents,omitempty" gorm:"->;-:migration"` // cheapest session type, filled in for the directory
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	AvailableSlots []AvailableSlot `json:"available_slots,omitempty"`
-	Bookings       []Booking       `json:"bookings,omitempty"`
+	Languages          []ExpertLanguage    `json:"languages,omitempty"`
+	AvailableSlots     []AvailableSlot     `json:"available_slots,omitempty"`
+	Bookings           []Booking           `json:"bookings,omitempty"`
+	CancellationPolicy *CancellationPolicy `json:"cancellation_policy,omitempty"`
+	Settings           *ExpertSettings     `json:"settings,omitempty"`
+	SessionTypes       []SessionType       `json:"session_types,omitempty"`
+}
+
+// ExpertLanguage is a language an expert holds sessions in.
+type ExpertLanguage struct {
+	ID       uint   `json:"-" gorm:"primaryKey"`
+	ExpertID uint   `json:"-" gorm:"not null;uniqueIndex:idx_expert_language"`
+	Language string `json:"language" gorm:"not null;size:8;uniqueIndex:idx_expert_language;index"` // ISO 639-1 code, e.g. en
+}
+
+// SessionType is a kind of session an expert offers. Booking one carves its
+// duration out of the expert's availability.
+type SessionType struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	ExpertID        uint           `json:"expert_id" gorm:"not null;index"`
+	Name            string         `json:"name" gorm:"not null"`
+	Description     string         `json:"description"`
+	DurationMinutes int            `json:"duration_minutes" gorm:"not null"`
+	PriceCents      int64          `json:"price_cents"`
+	Currency        string         `json:"currency" gorm:"default:USD"`
+	IsActive        bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Late cancellation outcomes
+const (
+	LateCancellationBlock  = "block"   // the cancellation is refused
+	LateCancellationNoShow = "no_show" // the booking is recorded as a no-show
+	LateCancellationFee    = "fee"     // cancelled and flagged for a late fee
+)
+
+// CancellationPolicy governs how bookings with an expert can be cancelled.
+// Experts without one follow the platform default from config.
+type CancellationPolicy struct {
+	ID                             uint      `json:"id,omitempty" gorm:"primaryKey"`
+	ExpertID                       uint      `json:"expert_id,omitempty" gorm:"uniqueIndex;not null"`
+	ClientCutoffMinutes            int       `json:"client_cutoff_minutes"`              // how long before the start clients can cancel
+	ExpertCutoffMinutes            int       `json:"expert_cutoff_minutes"`              // how long before the start experts can cancel
+	MaxClientCancellationsPerMonth int       `json:"max_client_cancellations_per_month"` // 0 means unlimited
+	LateCancellationOutcome        string    `json:"late_cancellation_outcome"`          // block, no_show, fee
+	IsDefault                      bool      `json:"is_default" gorm:"-"`                // the platform default applies
+	CreatedAt                      time.Time `json:"created_at,omitempty"`
+	UpdatedAt                      time.Time `json:"updated_at,omitempty"`
+}
+
+// ExpertSettings are the scheduling rules for bookings with an expert.
+// Experts without them follow the platform defaults from config.
+type ExpertSettings struct {
+	ID                  uint      `json:"id,omitempty" gorm:"primaryKey"`
+	ExpertID            uint      `json:"expert_id,omitempty" gorm:"uniqueIndex;not null"`
+	BufferBeforeMinutes int       `json:"buffer_before_minutes"` // free time needed before a session
+	BufferAfterMinutes  int       `json:"buffer_after_minutes"`  // free time needed after a session
+	MinNoticeMinutes    int       `json:"min_notice_minutes"`    // how far ahead sessions must be booked
+	MaxHorizonDays      int       `json:"max_horizon_days"`      // how far out sessions can be booked, 0 means unlimited
+	MaxSessionsPerDay   int       `json:"max_sessions_per_day"`  // 0 means unlimited
+	IsDefault           bool      `json:"is_default" gorm:"-"`   // the platform defaults apply
+	CreatedAt           time.Time `json:"created_at,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at,omitempty"`
 }
 
 type Booking struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	UserID       uint           `json:"user_id" gorm:"not null"`
-	User         User           `json:"user"`
-	ExpertID     uint           `json:"expert_id" gorm:"not null"`
-	Expert       Expert         `json:"expert"`
-	StartTime    time.Time      `json:"start_time" gorm:"not null"`
-	EndTime      time.Time      `json:"end_time" gorm:"not null"`
-	Status       string         `json:"status" gorm:"default:pending"` // pending, confirmed, rejected, cancelled, completed
-	Notes        string         `json:"notes"`
-	Format       string         `json:"format"` // online, offline
-	CancelReason string         `json:"cancel_reason"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	UserID           uint           `json:"user_id" gorm:"not null"`
+	User             User           `json:"user"`
+	ExpertID         uint           `json:"expert_id" gorm:"not null"`
+	Expert           Expert         `json:"expert"`
+	SlotID           *uint          `json:"slot_id" gorm:"index"`
+	SeriesID         *uint          `json:"series_id,omitempty" gorm:"index"`
+	SeriesIndex      int            `json:"series_index,omitempty"` // 1-based occurrence number
+	SessionTypeID    *uint          `json:"session_type_id,omitempty" gorm:"index"`
+	SessionType      *SessionType   `json:"session_type,omitempty"`
+	PriceCents       int64          `json:"price_cents"` // price of the session type when booked
+	Currency         string         `json:"currency,omitempty"`
+	StartTime        time.Time      `json:"start_time" gorm:"not null"`
+	EndTime          time.Time      `json:"end_time" gorm:"not null"`
+	Status           string         `json:"status" gorm:"default:pending"` // see BookingStatus* constants
+	Notes            string         `json:"notes"`
+	Format           string         `json:"format"` // online, offline
+	CancelReason     string         `json:"cancel_reason"`
+	CancelledBy      string         `json:"cancelled_by,omitempty"` // client, expert, admin
+	CancelledAt      *time.Time     `json:"cancelled_at,omitempty"`
+	LateCancellation bool           `json:"late_cancellation"` // cancelled within the policy cutoff
+	LateFeeDue       bool           `json:"late_fee_due"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
 	Feedback *Feedback `json:"feedback,omitempty"`
 }
 
+// Booking statuses. A booking starts pending and moves through the
+// lifecycle enforced by services.BookingService.TransitionBooking.
+const (
+	BookingStatusPending    = "pending"
+	BookingStatusConfirmed  = "confirmed"
+	BookingStatusRejected   = "rejected"
+	BookingStatusInProgress = "in_progress"
+	BookingStatusCompleted  = "completed"
+	BookingStatusNoShow     = "no_show"
+	BookingStatusCancelled  = "cancelled"
+	BookingStatusMissed     = "missed"
+)
+
+// Reschedule statuses
+const (
+	RescheduleStatusPending   = "pending"   // waiting for the other party
+	RescheduleStatusApplied   = "applied"   // the booking was moved
+	RescheduleStatusDeclined  = "declined"  // the other party refused
+	RescheduleStatusCancelled = "cancelled" // the booking ended while pending
+)
+
+// BookingReschedule records a move of a booking to a new time. Pending
+// requests keep the new slot claimed until they are decided.
+type BookingReschedule struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	BookingID       uint       `json:"booking_id" gorm:"not null;index"`
+	Booking         *Booking   `json:"booking,omitempty"`
+	RequestedBy     uint       `json:"requested_by" gorm:"not null"`
+	RequestedByRole string     `json:"requested_by_role" gorm:"not null"` // client, expert, admin
+	OldStartTime    time.Time  `json:"old_start_time" gorm:"not null"`
+	OldEndTime      time.Time  `json:"old_end_time" gorm:"not null"`
+	OldSlotID       *uint      `json:"old_slot_id"`
+	NewStartTime    time.Time  `json:"new_start_time" gorm:"not null"`
+	NewEndTime      time.Time  `json:"new_end_time" gorm:"not null"`
+	NewSlotID       *uint      `json:"new_slot_id"`
+	Status          string     `json:"status" gorm:"not null;index"` // see RescheduleStatus* constants
+	Reason          string     `json:"reason"`
+	DecidedBy       *uint      `json:"decided_by"`
+	DecidedAt       *time.Time `json:"decided_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Booking series frequencies
+const (
+	SeriesFrequencyWeekly   = "weekly"
+	SeriesFrequencyBiweekly = "biweekly"
+)
+
+// How a series is booked when some occurrences can't be
+const (
+	SeriesModeAllOrNothing  = "all_or_nothing"
+	SeriesModeSkipConflicts = "skip_conflicts"
+)
+
+// Booking series statuses
+const (
+	SeriesStatusActive    = "active"
+	SeriesStatusCancelled = "cancelled" // no occurrence is left to attend
+)
+
+// BookingSeries is a recurring booking. Each occurrence is a regular
+// Booking linked to the series.
+type BookingSeries struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	UserID        uint           `json:"user_id" gorm:"not null;index"`
+	User          User           `json:"user"`
+	ExpertID      uint           `json:"expert_id" gorm:"not null;index"`
+	Expert        Expert         `json:"expert"`
+	Frequency     string         `json:"frequency" gorm:"not null"` // weekly, biweekly
+	Occurrences   int            `json:"occurrences" gorm:"not null"`
+	StartTime     time.Time      `json:"start_time" gorm:"not null"` // first occurrence
+	EndTime       time.Time      `json:"end_time" gorm:"not null"`
+	Mode          string         `json:"mode" gorm:"not null"`
+	SessionTypeID *uint          `json:"session_type_id,omitempty"`
+	Status        string         `json:"status" gorm:"not null;default:active"`
+	Notes         string         `json:"notes"`
+	Format        string         `json:"format"` // online, offline
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Bookings []Booking `json:"bookings,omitempty" gorm:"foreignKey:SeriesID"`
+}
+
 type AvailableSlot struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	ExpertID  uint           `json:"expert_id" gorm:"not null"`
-	Expert    Expert         `json:"expert"`
-	StartTime time.Time      `json:"start_time" gorm:"not null"`
-	EndTime   time.Time      `json:"end_time" gorm:"not null"`
-	IsBooked  bool           `json:"is_booked" gorm:"default:false"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	ExpertID       uint           `json:"expert_id" gorm:"not null;index:idx_slots_expert_start,priority:1"`
+	Expert         Expert         `json:"expert"`
+	StartTime      time.Time      `json:"start_time" gorm:"not null;index:idx_slots_expert_start,priority:2;index:idx_slots_open_start,priority:2"`
+	EndTime        time.Time      `json:"end_time" gorm:"not null"`
+	IsBooked       bool           `json:"is_booked" gorm:"default:false;index:idx_slots_open_start,priority:1"` // every seat is taken
+	Capacity       int            `json:"capacity" gorm:"not null;default:1"`
+	BookedCount    int            `json:"booked_count" gorm:"not null;default:0"`
+	RemainingSeats int            `json:"remaining_seats" gorm:"-"` // filled in for listings
+	RuleID         *uint          `json:"rule_id,omitempty" gorm:"index"`
+	OverrideID     *uint          `json:"override_id,omitempty" gorm:"index"`
+	WindowStart    *time.Time     `json:"-"` // window this slot was carved out of
+	WindowEnd      *time.Time     `json:"-"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// AvailabilityRule is a weekly recurring availability window that is
+// materialized into AvailableSlots of SessionMinutes each.
+type AvailabilityRule struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	ExpertID       uint           `json:"expert_id" gorm:"not null;index"`
+	Weekdays       string         `json:"weekdays" gorm:"not null"`   // RRULE BYDAY codes, e.g. MO,TU,WE
+	StartTime      string         `json:"start_time" gorm:"not null"` // HH:MM
+	EndTime        string         `json:"end_time" gorm:"not null"`   // HH:MM
+	SessionMinutes int            `json:"session_minutes" gorm:"not null"`
+	Capacity       int            `json:"capacity" gorm:"not null;default:1"` // seats per generated slot
+	ValidFrom      string         `json:"valid_from"`                         // YYYY-MM-DD, empty means no lower bound
+	ValidUntil     string         `json:"valid_until"`                        // YYYY-MM-DD, empty means no upper bound
+	ExceptDates    string         `json:"except_dates"`                       // comma separated YYYY-MM-DD (RRULE EXDATE)
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Availability override kinds
+const (
+	OverrideUnavailable = "unavailable"
+	OverrideExtra       = "extra"
+)
+
+// AvailabilityOverride changes availability on a single date: either
+// blocking time (holidays) or adding one-off extra hours.
+type AvailabilityOverride struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	ExpertID       uint           `json:"expert_id" gorm:"not null;index"`
+	Date           string         `json:"date" gorm:"not null"` // YYYY-MM-DD
+	Kind           string         `json:"kind" gorm:"not null"` // unavailable, extra
+	StartTime      string         `json:"start_time"`           // HH:MM, empty with unavailable blocks the whole day
+	EndTime        string         `json:"end_time"`             // HH:MM
+	SessionMinutes int            `json:"session_minutes"`
+	Reason         string         `json:"reason"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Waitlist entry statuses
+const (
+	WaitlistStatusWaiting   = "waiting"   // in line for a freed slot
+	WaitlistStatusOffered   = "offered"   // holding an offer
+	WaitlistStatusBooked    = "booked"    // claimed an offer
+	WaitlistStatusCancelled = "cancelled" // left the waitlist
+)
+
+// WaitlistEntry puts a user in line for slots of an expert that free up,
+// optionally only a single slot or slots within a date range.
+type WaitlistEntry struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	User        User       `json:"-"`
+	ExpertID    uint       `json:"expert_id" gorm:"not null;index"`
+	SlotID      *uint      `json:"slot_id,omitempty"`
+	WindowStart *time.Time `json:"from,omitempty"`
+	WindowEnd   *time.Time `json:"until,omitempty"`
+	Status      string     `json:"status" gorm:"not null;index"` // see WaitlistStatus* constants
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Waitlist offer statuses
+const (
+	OfferStatusPending  = "pending"
+	OfferStatusClaimed  = "claimed"
+	OfferStatusDeclined = "declined"
+	OfferStatusExpired  = "expired"
+)
+
+// WaitlistOffer holds a freed slot for a waitlisted user until it is
+// claimed or expires, after which it passes to the next in line.
+type WaitlistOffer struct {
+	ID        uint          `json:"id" gorm:"primaryKey"`
+	EntryID   uint          `json:"entry_id" gorm:"not null;index"`
+	UserID    uint          `json:"user_id" gorm:"not null;index"`
+	SlotID    uint          `json:"slot_id" gorm:"not null;index"`
+	Slot      AvailableSlot `json:"slot"`
+	ExpiresAt time.Time     `json:"expires_at" gorm:"not null;index"`
+	Status    string        `json:"status" gorm:"not null;index"` // see OfferStatus* constants
+	BookingID *uint         `json:"booking_id,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
 }
 
 type Notification struct {
@@ -89,6 +350,16 @@ type Notification struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// RecoveryCode is a single-use fallback for a lost authenticator. Only a
+// hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
 type Feedback struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	BookingID uint           `json:"booking_id" gorm:"not null"`
@@ -2,11 +2,13 @@
 package routes
 
 import (
+	"consultation-booking/internal/auth"
 	"consultation-booking/internal/handlers"
 	"consultation-booking/internal/middleware"
 	"consultation-booking/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 func SetupRoutes(
@@ -15,12 +17,18 @@ func SetupRoutes(
 	expertService *services.ExpertService,
 	bookingService *services.BookingService,
 	notificationService *services.NotificationService,
+	availabilityService *services.AvailabilityService,
+	waitlistService *services.WaitlistService,
+	redisClient *redis.Client,
+	tokens *auth.TokenManager,
 ) {
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userService)
-	expertHandler := handlers.NewExpertHandler(expertService)
-	bookingHandler := handlers.NewBookingHandler(bookingService)
+	expertHandler := handlers.NewExpertHandler(expertService, bookingService)
+	bookingHandler := handlers.NewBookingHandler(bookingService, expertService)
 	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	availabilityHandler := handlers.NewAvailabilityHandler(availabilityService)
+	waitlistHandler := handlers.NewWaitlistHandler(waitlistService)
 
 	// Public routes
 	api := router.Group("/api/v1")
@@ -30,22 +38,41 @@ func SetupRoutes(
 		{
 			auth.POST("/register", userHandler.Register)
 			auth.POST("/login", userHandler.Login)
+			auth.POST("/login/2fa", userHandler.LoginTwoFactor)
 			auth.POST("/refresh", userHandler.RefreshToken)
+			auth.POST("/password/forgot", userHandler.ForgotPassword)
+			auth.POST("/password/reset", userHandler.ResetPassword)
+			auth.POST("/verify-email", userHandler.VerifyEmail)
 		}
 
 		// Public expert routes
 		experts := api.Group("/experts")
 		{
 			experts.GET("", expertHandler.GetExperts)
+			experts.GET("/search", expertHandler.SearchAvailability)
 			experts.GET("/:id", expertHandler.GetExpertByID)
 			experts.GET("/:id/slots", expertHandler.GetAvailableSlots)
+			experts.GET("/:id/next-available", expertHandler.GetNextAvailable)
+			experts.GET("/:id/session-types", expertHandler.GetExpertSessionTypes)
 		}
 	}
 
 	// Protected routes
 	protected := api.Group("/")
-	protected.Use(middleware.AuthMiddleware("your-secret-key"))
+	protected.Use(middleware.AuthMiddleware(tokens, redisClient))
 	{
+		// Session routes
+		session := protected.Group("/auth")
+		{
+			session.POST("/logout", userHandler.Logout)
+			session.POST("/logout-all", userHandler.LogoutAll)
+			session.POST("/verify-email/resend", userHandler.ResendVerification)
+			session.POST("/2fa/enroll", userHandler.EnrollTwoFactor)
+			session.POST("/2fa/verify", userHandler.VerifyTwoFactor)
+			session.POST("/2fa/disable", userHandler.DisableTwoFactor)
+			session.POST("/2fa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+		}
+
 		// User routes
 		user := protected.Group("/user")
 		{
@@ -56,11 +83,36 @@ func SetupRoutes(
 
 		// Expert routes
 		expert := protected.Group("/expert")
-		expert.Use(middleware.RoleMiddleware("expert", "admin"))
+		expert.Use(middleware.RoleMiddleware("expert", "admin"), middleware.ExpertMiddleware(expertService))
 		{
+			expert.PUT("/profile", expertHandler.UpdateProfile)
+			expert.GET("/cancellation-policy", expertHandler.GetCancellationPolicy)
+			expert.PUT("/cancellation-policy", expertHandler.UpdateCancellationPolicy)
+			expert.DELETE("/cancellation-policy", expertHandler.ResetCancellationPolicy)
+			expert.GET("/settings", expertHandler.GetSettings)
+			expert.PUT("/settings", expertHandler.UpdateSettings)
+			expert.DELETE("/settings", expertHandler.ResetSettings)
+			expert.GET("/session-types", expertHandler.GetSessionTypes)
+			expert.POST("/session-types", expertHandler.CreateSessionType)
+			expert.PUT("/session-types/:id", expertHandler.UpdateSessionType)
+			expert.DELETE("/session-types/:id", expertHandler.DeleteSessionType)
+			expert.GET("/slots", expertHandler.GetExpertSlots)
 			expert.POST("/slots", expertHandler.CreateAvailableSlot)
+			expert.POST("/slots/bulk", expertHandler.BulkCreateSlots)
+			expert.PUT("/slots/:id", expertHandler.UpdateSlot)
+			expert.DELETE("/slots/:id", expertHandler.DeleteSlot)
+			expert.GET("/slots/:id/attendees", expertHandler.GetSlotAttendees)
 			expert.GET("/bookings", expertHandler.GetExpertBookings)
 			expert.PUT("/bookings/:id/status", expertHandler.UpdateBookingStatus)
+
+			expert.GET("/availability-rules", availabilityHandler.GetRules)
+			expert.POST("/availability-rules", availabilityHandler.CreateRule)
+			expert.PUT("/availability-rules/:id", availabilityHandler.UpdateRule)
+			expert.DELETE("/availability-rules/:id", availabilityHandler.DeleteRule)
+
+			expert.GET("/availability-overrides", availabilityHandler.GetOverrides)
+			expert.POST("/availability-overrides", availabilityHandler.CreateOverride)
+			expert.DELETE("/availability-overrides/:id", availabilityHandler.DeleteOverride)
 		}
 
 		// Booking routes
@@ -69,6 +121,37 @@ func SetupRoutes(
 			booking.POST("", bookingHandler.CreateBooking)
 			booking.GET("/:id", bookingHandler.GetBooking)
 			booking.PUT("/:id/cancel", bookingHandler.CancelBooking)
+			booking.POST("/:id/reschedule", bookingHandler.RescheduleBooking)
+			booking.GET("/:id/reschedules", bookingHandler.GetReschedules)
+			booking.POST("/:id/reschedule/approve", bookingHandler.ApproveReschedule)
+			booking.POST("/:id/reschedule/decline", bookingHandler.DeclineReschedule)
+			booking.PUT("/:id/series/cancel", bookingHandler.CancelSeries)
+			booking.POST("/:id/series/reschedule", bookingHandler.RescheduleSeries)
+		}
+
+		// Booking series routes
+		series := protected.Group("/booking-series")
+		{
+			series.POST("", bookingHandler.CreateSeries)
+			series.GET("/:id", bookingHandler.GetSeries)
+		}
+
+		// Slot hold routes
+		slots := protected.Group("/slots")
+		{
+			slots.POST("/:id/hold", bookingHandler.HoldSlot)
+			slots.DELETE("/:id/hold", bookingHandler.ReleaseHold)
+		}
+
+		// Waitlist routes
+		waitlist := protected.Group("/waitlist")
+		{
+			waitlist.POST("", waitlistHandler.JoinWaitlist)
+			waitlist.GET("", waitlistHandler.GetEntries)
+			waitlist.DELETE("/:id", waitlistHandler.LeaveWaitlist)
+			waitlist.GET("/offers", waitlistHandler.GetOffers)
+			waitlist.POST("/offers/:id/claim", waitlistHandler.ClaimOffer)
+			waitlist.POST("/offers/:id/decline", waitlistHandler.DeclineOffer)
 		}
 
 		// Notification routes
@@ -84,6 +167,9 @@ func SetupRoutes(
 		admin.Use(middleware.RoleMiddleware("admin"))
 		{
 			admin.POST("/experts", expertHandler.CreateExpert)
+			admin.PUT("/users/:id/active", userHandler.SetUserActive)
+			admin.GET("/settings/two-factor", userHandler.GetTwoFactorPolicy)
+			admin.PUT("/settings/two-factor", userHandler.UpdateTwoFactorPolicy)
 			admin.GET("/bookings", bookingHandler.GetAllBookings)
 			admin.GET("/stats", bookingHandler.GetStats)
 		}
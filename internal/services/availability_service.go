@@ -0,0 +1,537 @@
+// internal/services/availability_service.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// How far ahead recurring rules are turned into bookable slots
+const availabilityHorizon = 28 * 24 * time.Hour
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrRuleNotFound     = errors.New("availability rule not found")
+	ErrOverrideNotFound = errors.New("availability override not found")
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+type AvailabilityService struct {
+	db    *gorm.DB
+	redis *redis.Client
+}
+
+type AvailabilityRuleRequest struct {
+	Weekdays       []string `json:"weekdays" binding:"required"` // MO, TU, WE, TH, FR, SA, SU
+	StartTime      string   `json:"start_time" binding:"required"`
+	EndTime        string   `json:"end_time" binding:"required"`
+	SessionMinutes int      `json:"session_minutes" binding:"required"`
+	Capacity       int      `json:"capacity" binding:"min=0"` // seats per session, 1 when omitted
+	ValidFrom      string   `json:"valid_from"`
+	ValidUntil     string   `json:"valid_until"`
+	ExceptDates    []string `json:"except_dates"`
+}
+
+type AvailabilityOverrideRequest struct {
+	Date           string `json:"date" binding:"required"`
+	Kind           string `json:"kind" binding:"required"`
+	StartTime      string `json:"start_time"`
+	EndTime        string `json:"end_time"`
+	SessionMinutes int    `json:"session_minutes"`
+	Reason         string `json:"reason"`
+}
+
+func NewAvailabilityService(db *gorm.DB, redis *redis.Client) *AvailabilityService {
+	return &AvailabilityService{
+		db:    db,
+		redis: redis,
+	}
+}
+
+func (s *AvailabilityService) CreateRule(expertID uint, req AvailabilityRuleRequest) (*models.AvailabilityRule, error) {
+	rule := models.AvailabilityRule{ExpertID: expertID}
+	if err := applyRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.MaterializeSlots(expertID); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *AvailabilityService) GetRules(expertID uint) ([]models.AvailabilityRule, error) {
+	var rules []models.AvailabilityRule
+	err := s.db.Where("expert_id = ?", expertID).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func (s *AvailabilityService) UpdateRule(expertID, ruleID uint, req AvailabilityRuleRequest) (*models.AvailabilityRule, error) {
+	var rule models.AvailabilityRule
+	if err := s.db.Where("id = ? AND expert_id = ?", ruleID, expertID).First(&rule).Error; err != nil {
+		return nil, ErrRuleNotFound
+	}
+
+	if err := applyRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.MaterializeSlots(expertID); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *AvailabilityService) DeleteRule(expertID, ruleID uint) error {
+	result := s.db.Where("id = ? AND expert_id = ?", ruleID, expertID).Delete(&models.AvailabilityRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+
+	return s.MaterializeSlots(expertID)
+}
+
+func (s *AvailabilityService) CreateOverride(expertID uint, req AvailabilityOverrideRequest) (*models.AvailabilityOverride, error) {
+	override := models.AvailabilityOverride{
+		ExpertID:       expertID,
+		Date:           req.Date,
+		Kind:           req.Kind,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		SessionMinutes: req.SessionMinutes,
+		Reason:         req.Reason,
+	}
+	if err := validateOverride(&override); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&override).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.MaterializeSlots(expertID); err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (s *AvailabilityService) GetOverrides(expertID uint) ([]models.AvailabilityOverride, error) {
+	var overrides []models.AvailabilityOverride
+	err := s.db.Where("expert_id = ?", expertID).Order("date").Find(&overrides).Error
+	return overrides, err
+}
+
+func (s *AvailabilityService) DeleteOverride(expertID, overrideID uint) error {
+	result := s.db.Where("id = ? AND expert_id = ?", overrideID, expertID).Delete(&models.AvailabilityOverride{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOverrideNotFound
+	}
+
+	return s.MaterializeSlots(expertID)
+}
+
+// MaterializeSlots brings the expert's generated slots in line with their
+// rules and overrides and drops the cached slot listing.
+func (s *AvailabilityService) MaterializeSlots(expertID uint) error {
+	if err := materializeAvailability(s.db, expertID, time.Now()); err != nil {
+		return err
+	}
+
+	invalidateSlotCache(s.redis, expertID)
+	return nil
+}
+
+// MaterializeAll refreshes generated slots for every expert that has
+// rules or overrides. Used by the worker to roll the horizon forward.
+func (s *AvailabilityService) MaterializeAll() error {
+	var expertIDs []uint
+	if err := s.db.Model(&models.AvailabilityRule{}).Distinct().Pluck("expert_id", &expertIDs).Error; err != nil {
+		return err
+	}
+
+	var overrideExpertIDs []uint
+	if err := s.db.Model(&models.AvailabilityOverride{}).Distinct().Pluck("expert_id", &overrideExpertIDs).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[uint]bool)
+	for _, id := range append(expertIDs, overrideExpertIDs...) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if err := s.MaterializeSlots(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatedSlot is a slot derived from a rule or an extra-hours override.
+type generatedSlot struct {
+	start      time.Time
+	end        time.Time
+	ruleID     *uint
+	overrideID *uint
+	capacity   int
+}
+
+// materializeAvailability creates the slots the expert's rules and
+// overrides produce within the horizon and removes unbooked generated
+// slots that are no longer produced. Booked slots are never touched.
+func materializeAvailability(db *gorm.DB, expertID uint, now time.Time) error {
+	var expert models.Expert
+	if err := db.First(&expert, expertID).Error; err != nil {
+		return err
+	}
+	loc := locationOrUTC(expert.Timezone)
+
+	var rules []models.AvailabilityRule
+	if err := db.Where("expert_id = ?", expertID).Find(&rules).Error; err != nil {
+		return err
+	}
+
+	var overrides []models.AvailabilityOverride
+	if err := db.Where("expert_id = ? AND date >= ?", expertID, now.In(loc).Format(dateLayout)).Find(&overrides).Error; err != nil {
+		return err
+	}
+
+	desired := generateSlots(rules, overrides, now, now.Add(availabilityHorizon), loc)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.AvailableSlot
+		if err := tx.Where(
+			"expert_id = ? AND start_time > ? AND (rule_id IS NOT NULL OR override_id IS NOT NULL)",
+			expertID, now,
+		).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		wanted := make(map[string]generatedSlot, len(desired))
+		for _, g := range desired {
+			wanted[generatedKey(g.start, g.end, g.ruleID, g.overrideID)] = g
+		}
+
+		var stale []uint
+		matched := make(map[string]bool)
+		for _, slot := range existing {
+			// Pieces carved out of a window all belong to it
+			start, end := slot.StartTime, slot.EndTime
+			if slot.WindowStart != nil && slot.WindowEnd != nil {
+				start, end = *slot.WindowStart, *slot.WindowEnd
+			}
+
+			key := generatedKey(start, end, slot.RuleID, slot.OverrideID)
+			if g, ok := wanted[key]; ok {
+				matched[key] = true
+				// Follow capacity changes of the rule, never below the seats taken
+				if slot.WindowStart == nil && g.capacity != slot.Capacity && g.capacity >= slot.BookedCount {
+					if err := tx.Model(&models.AvailableSlot{}).Where("id = ?", slot.ID).Updates(map[string]interface{}{
+						"capacity":  g.capacity,
+						"is_booked": slot.BookedCount >= g.capacity,
+					}).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if !slot.IsBooked && slot.BookedCount == 0 {
+				stale = append(stale, slot.ID)
+			}
+		}
+		for key := range matched {
+			delete(wanted, key)
+		}
+
+		// Slots with any seat taken are kept
+		if len(stale) > 0 {
+			if err := tx.Where("id IN ? AND is_booked = ? AND booked_count = ?", stale, false, 0).Delete(&models.AvailableSlot{}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, g := range wanted {
+			// Manually created slots take precedence over generated ones
+			var overlapping int64
+			if err := tx.Model(&models.AvailableSlot{}).Where(
+				"expert_id = ? AND start_time < ? AND end_time > ?",
+				expertID, g.end, g.start,
+			).Count(&overlapping).Error; err != nil {
+				return err
+			}
+			if overlapping > 0 {
+				continue
+			}
+
+			slot := models.AvailableSlot{
+				ExpertID:   expertID,
+				StartTime:  g.start.UTC(),
+				EndTime:    g.end.UTC(),
+				RuleID:     g.ruleID,
+				OverrideID: g.overrideID,
+				Capacity:   g.capacity,
+			}
+			if err := tx.Create(&slot).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// generateSlots expands rules and extra-hours overrides into sessions
+// starting within (from, to], skipping except dates and blocked time.
+// Wall-clock times are read in loc, so sessions keep their local hours
+// across DST changes.
+func generateSlots(rules []models.AvailabilityRule, overrides []models.AvailabilityOverride, from, to time.Time, loc *time.Location) []generatedSlot {
+	blocked := make(map[string][]models.AvailabilityOverride)
+	var extras []models.AvailabilityOverride
+	for _, o := range overrides {
+		if o.Kind == models.OverrideUnavailable {
+			blocked[o.Date] = append(blocked[o.Date], o)
+		} else {
+			extras = append(extras, o)
+		}
+	}
+
+	var slots []generatedSlot
+	add := func(date time.Time, startClock, endClock string, minutes, capacity int, ruleID, overrideID *uint) {
+		if minutes <= 0 {
+			return
+		}
+		start, err := clockOn(date, startClock, loc)
+		if err != nil {
+			return
+		}
+		end, err := clockOn(date, endClock, loc)
+		if err != nil {
+			return
+		}
+
+		session := time.Duration(minutes) * time.Minute
+		for t := start; !t.Add(session).After(end); t = t.Add(session) {
+			if !t.After(from) || t.After(to) {
+				continue
+			}
+			if isBlocked(blocked[date.Format(dateLayout)], date, t, t.Add(session), loc) {
+				continue
+			}
+			slots = append(slots, generatedSlot{start: t, end: t.Add(session), ruleID: ruleID, overrideID: overrideID, capacity: seatCount(capacity)})
+		}
+	}
+
+	first := time.Date(from.In(loc).Year(), from.In(loc).Month(), from.In(loc).Day(), 0, 0, 0, 0, loc)
+	for day := first; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		for i := range rules {
+			rule := &rules[i]
+			if !ruleAppliesOn(rule, day, date) {
+				continue
+			}
+			add(day, rule.StartTime, rule.EndTime, rule.SessionMinutes, rule.Capacity, &rule.ID, nil)
+		}
+	}
+
+	for i := range extras {
+		extra := &extras[i]
+		day, err := time.ParseInLocation(dateLayout, extra.Date, loc)
+		if err != nil {
+			continue
+		}
+		add(day, extra.StartTime, extra.EndTime, extra.SessionMinutes, 1, nil, &extra.ID)
+	}
+
+	return slots
+}
+
+func ruleAppliesOn(rule *models.AvailabilityRule, day time.Time, date string) bool {
+	if rule.ValidFrom != "" && date < rule.ValidFrom {
+		return false
+	}
+	if rule.ValidUntil != "" && date > rule.ValidUntil {
+		return false
+	}
+
+	for _, except := range splitList(rule.ExceptDates) {
+		if except == date {
+			return false
+		}
+	}
+
+	for _, code := range splitList(rule.Weekdays) {
+		if weekdayCodes[code] == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlocked reports whether [start, end) overlaps an unavailable override
+// for the day. Overrides without times block the whole day.
+func isBlocked(blocks []models.AvailabilityOverride, day, start, end time.Time, loc *time.Location) bool {
+	for _, b := range blocks {
+		if b.StartTime == "" || b.EndTime == "" {
+			return true
+		}
+
+		blockStart, err1 := clockOn(day, b.StartTime, loc)
+		blockEnd, err2 := clockOn(day, b.EndTime, loc)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if start.Before(blockEnd) && end.After(blockStart) {
+			return true
+		}
+	}
+	return false
+}
+
+// clockOn returns the wall-clock time HH:MM on the given day in loc.
+func clockOn(day time.Time, clock string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, loc), nil
+}
+
+func generatedKey(start, end time.Time, ruleID, overrideID *uint) string {
+	var rule, override uint
+	if ruleID != nil {
+		rule = *ruleID
+	}
+	if overrideID != nil {
+		override = *overrideID
+	}
+	return fmt.Sprintf("%d-%d-%d-%d", start.Unix(), end.Unix(), rule, override)
+}
+
+func applyRuleRequest(rule *models.AvailabilityRule, req AvailabilityRuleRequest) error {
+	if len(req.Weekdays) == 0 {
+		return errors.New("at least one weekday is required")
+	}
+
+	codes := make([]string, 0, len(req.Weekdays))
+	for _, day := range req.Weekdays {
+		code := strings.ToUpper(strings.TrimSpace(day))
+		if _, ok := weekdayCodes[code]; !ok {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+		codes = append(codes, code)
+	}
+
+	if err := validateWindow(req.StartTime, req.EndTime, req.SessionMinutes); err != nil {
+		return err
+	}
+
+	for _, date := range append([]string{req.ValidFrom, req.ValidUntil}, req.ExceptDates...) {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+
+	if req.ValidFrom != "" && req.ValidUntil != "" && req.ValidUntil < req.ValidFrom {
+		return errors.New("valid_until must not be before valid_from")
+	}
+
+	rule.Weekdays = strings.Join(codes, ",")
+	rule.StartTime = req.StartTime
+	rule.EndTime = req.EndTime
+	rule.SessionMinutes = req.SessionMinutes
+	rule.Capacity = seatCount(req.Capacity)
+	rule.ValidFrom = req.ValidFrom
+	rule.ValidUntil = req.ValidUntil
+	rule.ExceptDates = strings.Join(req.ExceptDates, ",")
+	return nil
+}
+
+func validateOverride(o *models.AvailabilityOverride) error {
+	if _, err := time.Parse(dateLayout, o.Date); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", o.Date)
+	}
+
+	switch o.Kind {
+	case models.OverrideUnavailable:
+		if o.StartTime == "" && o.EndTime == "" {
+			return nil
+		}
+		return validateWindow(o.StartTime, o.EndTime, 0)
+	case models.OverrideExtra:
+		if o.SessionMinutes <= 0 {
+			return errors.New("session_minutes is required for extra hours")
+		}
+		return validateWindow(o.StartTime, o.EndTime, o.SessionMinutes)
+	}
+
+	return fmt.Errorf("invalid override kind %q, expected %s or %s", o.Kind, models.OverrideUnavailable, models.OverrideExtra)
+}
+
+// validateWindow checks an HH:MM window. A zero session length skips the
+// session check.
+func validateWindow(startClock, endClock string, sessionMinutes int) error {
+	start, err := time.Parse("15:04", startClock)
+	if err != nil {
+		return fmt.Errorf("invalid start time %q, expected HH:MM", startClock)
+	}
+	end, err := time.Parse("15:04", endClock)
+	if err != nil {
+		return fmt.Errorf("invalid end time %q, expected HH:MM", endClock)
+	}
+	if !end.After(start) {
+		return errors.New("end time must be after start time")
+	}
+
+	if sessionMinutes == 0 {
+		return nil
+	}
+	if sessionMinutes < 0 || time.Duration(sessionMinutes)*time.Minute > end.Sub(start) {
+		return errors.New("session length must fit inside the availability window")
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
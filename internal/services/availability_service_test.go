@@ -0,0 +1,93 @@
+// internal/services/availability_service_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBookSessionSpanningRuleSlots(t *testing.T) {
+	env := newTestEnv(t)
+	_, expert := env.createExpert(t)
+	client := env.createUser(t, "user")
+
+	if err := env.db.Create(&models.AvailabilityRule{
+		ExpertID:       expert.ID,
+		Weekdays:       "MO,TU,WE,TH,FR,SA,SU",
+		StartTime:      "09:00",
+		EndTime:        "12:00",
+		SessionMinutes: 30,
+		Capacity:       1,
+	}).Error; err != nil {
+		t.Fatalf("creating rule: %v", err)
+	}
+	if err := materializeAvailability(env.db, expert.ID, time.Now()); err != nil {
+		t.Fatalf("materializing: %v", err)
+	}
+
+	var first models.AvailableSlot
+	if err := env.db.Where("expert_id = ? AND rule_id IS NOT NULL AND start_time > ?", expert.ID, time.Now().Add(24*time.Hour)).
+		Order("start_time").First(&first).Error; err != nil {
+		t.Fatalf("finding a generated slot: %v", err)
+	}
+
+	// An hour long session covers two 30 minute sessions of the rule
+	start, end := first.StartTime, first.StartTime.Add(time.Hour)
+	booking, err := env.bookings.CreateBooking(client.ID, CreateBookingRequest{
+		ExpertID:  expert.ID,
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+
+	var slot models.AvailableSlot
+	env.db.First(&slot, *booking.SlotID)
+	if !slot.StartTime.Equal(start) || !slot.EndTime.Equal(end) || !slot.IsBooked {
+		t.Fatalf("booked slot %v-%v booked=%v, want %v-%v booked", slot.StartTime, slot.EndTime, slot.IsBooked, start, end)
+	}
+
+	// Regenerating must not put the joined sessions back
+	if err := materializeAvailability(env.db, expert.ID, time.Now()); err != nil {
+		t.Fatalf("materializing: %v", err)
+	}
+	var overlapping int64
+	env.db.Model(&models.AvailableSlot{}).Where("expert_id = ? AND start_time < ? AND end_time > ?", expert.ID, end, start).
+		Count(&overlapping)
+	if overlapping != 1 {
+		t.Fatalf("%d slots cover the booked hour, want 1", overlapping)
+	}
+}
+
+func TestSlotListingsDoNotMaterialize(t *testing.T) {
+	env := newTestEnv(t)
+	_, expert := env.createExpert(t)
+
+	// A rule written without MaterializeSlots has no slots until the worker
+	// or a rule change generates them
+	if err := env.db.Create(&models.AvailabilityRule{
+		ExpertID:       expert.ID,
+		Weekdays:       "MO,TU,WE,TH,FR,SA,SU",
+		StartTime:      "09:00",
+		EndTime:        "12:00",
+		SessionMinutes: 60,
+		Capacity:       1,
+	}).Error; err != nil {
+		t.Fatalf("creating rule: %v", err)
+	}
+
+	if _, err := env.experts.GetAvailableSlots(expert.ID); err != nil {
+		t.Fatalf("GetAvailableSlots: %v", err)
+	}
+	if _, err := env.experts.GetExpertSlots(expert.ID, time.Now(), time.Time{}); err != nil {
+		t.Fatalf("GetExpertSlots: %v", err)
+	}
+
+	var generated int64
+	env.db.Model(&models.AvailableSlot{}).Where("expert_id = ?", expert.ID).Count(&generated)
+	if generated != 0 {
+		t.Fatalf("listing slots wrote %d slots", generated)
+	}
+}
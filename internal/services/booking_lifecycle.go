@@ -0,0 +1,168 @@
+// internal/services/booking_lifecycle.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Roles an actor can play on a booking
+const (
+	ActorRoleClient = "client"
+	ActorRoleExpert = "expert"
+	ActorRoleAdmin  = "admin"
+	ActorRoleSystem = "system"
+)
+
+var (
+	ErrBookingNotFound        = errors.New("booking not found")
+	ErrUnknownBookingStatus   = errors.New("unknown booking status")
+	ErrIllegalTransition      = errors.New("illegal booking status transition")
+	ErrTransitionNotPermitted = errors.New("not permitted to perform this booking transition")
+)
+
+// Actor is whoever asks for a booking change: an authenticated user or
+// the system itself (worker jobs).
+type Actor struct {
+	UserID uint
+	Role   string // account role: user, expert, admin, system
+}
+
+func SystemActor() Actor {
+	return Actor{Role: ActorRoleSystem}
+}
+
+// TransitionError describes a rejected status change.
+type TransitionError struct {
+	From string
+	To   string
+	Role string
+	Err  error
+}
+
+func (e *TransitionError) Error() string {
+	if errors.Is(e.Err, ErrTransitionNotPermitted) {
+		return fmt.Sprintf("%s is not allowed to move a booking from %s to %s", e.Role, e.From, e.To)
+	}
+	return fmt.Sprintf("cannot move a booking from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// BookingTransition is passed to transition hooks after the status has
+// been written, inside the same transaction.
+type BookingTransition struct {
+	Booking *models.Booking
+	From    string
+	To      string
+	Actor   Actor
+	Role    string
+	Reason  string
+}
+
+// TransitionHook runs inside the transaction that changed the booking
+// status. Returning an error rolls the transition back.
+type TransitionHook func(tx *gorm.DB, t BookingTransition) error
+
+// bookingTransitions maps from -> to -> roles allowed to make that move.
+// Statuses without an entry are terminal.
+var bookingTransitions = map[string]map[string][]string{
+	models.BookingStatusPending: {
+		models.BookingStatusConfirmed: {ActorRoleExpert, ActorRoleAdmin},
+		models.BookingStatusRejected:  {ActorRoleExpert, ActorRoleAdmin},
+		models.BookingStatusCancelled: {ActorRoleClient, ActorRoleExpert, ActorRoleAdmin},
+		models.BookingStatusMissed:    {ActorRoleSystem, ActorRoleAdmin},
+	},
+	models.BookingStatusConfirmed: {
+		models.BookingStatusInProgress: {ActorRoleExpert, ActorRoleAdmin, ActorRoleSystem},
+		models.BookingStatusCancelled:  {ActorRoleClient, ActorRoleExpert, ActorRoleAdmin},
+		models.BookingStatusNoShow:     {ActorRoleExpert, ActorRoleAdmin},
+	},
+	models.BookingStatusInProgress: {
+		models.BookingStatusCompleted: {ActorRoleExpert, ActorRoleAdmin, ActorRoleSystem},
+		models.BookingStatusNoShow:    {ActorRoleExpert, ActorRoleAdmin},
+	},
+}
+
+var knownBookingStatuses = map[string]bool{
+	models.BookingStatusPending:    true,
+	models.BookingStatusConfirmed:  true,
+	models.BookingStatusRejected:   true,
+	models.BookingStatusInProgress: true,
+	models.BookingStatusCompleted:  true,
+	models.BookingStatusNoShow:     true,
+	models.BookingStatusCancelled:  true,
+	models.BookingStatusMissed:     true,
+}
+
+// activeBookingStatuses are the statuses that occupy the expert's and the
+// client's time.
+var activeBookingStatuses = []string{
+	models.BookingStatusPending,
+	models.BookingStatusConfirmed,
+	models.BookingStatusInProgress,
+}
+
+// slotReleasingStatuses free the booked slot when entered.
+var slotReleasingStatuses = map[string]bool{
+	models.BookingStatusRejected:  true,
+	models.BookingStatusCancelled: true,
+	models.BookingStatusMissed:    true,
+}
+
+func IsValidBookingStatus(status string) bool {
+	return knownBookingStatuses[status]
+}
+
+func IsTerminalBookingStatus(status string) bool {
+	_, ok := bookingTransitions[status]
+	return !ok
+}
+
+// CheckBookingTransition reports whether role may move a booking from one
+// status to another.
+func CheckBookingTransition(from, to, role string) error {
+	if !IsValidBookingStatus(to) {
+		return ErrUnknownBookingStatus
+	}
+
+	roles, ok := bookingTransitions[from][to]
+	if !ok {
+		return &TransitionError{From: from, To: to, Role: role, Err: ErrIllegalTransition}
+	}
+
+	for _, allowed := range roles {
+		if allowed == role {
+			return nil
+		}
+	}
+
+	return &TransitionError{From: from, To: to, Role: role, Err: ErrTransitionNotPermitted}
+}
+
+// bookingRole resolves the role the actor plays on this booking. The
+// booking must have Expert loaded.
+func bookingRole(booking *models.Booking, actor Actor) string {
+	return partyRole(booking.UserID, booking.Expert.UserID, actor)
+}
+
+// partyRole resolves the role the actor plays between a client and an
+// expert's user.
+func partyRole(clientID, expertUserID uint, actor Actor) string {
+	switch {
+	case actor.Role == ActorRoleSystem:
+		return ActorRoleSystem
+	case actor.Role == "admin":
+		return ActorRoleAdmin
+	case clientID == actor.UserID:
+		return ActorRoleClient
+	case expertUserID == actor.UserID:
+		return ActorRoleExpert
+	}
+	return ""
+}
@@ -0,0 +1,316 @@
+// internal/services/booking_reschedule.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotReschedulable    = errors.New("only pending or confirmed bookings can be rescheduled")
+	ErrReschedulePending   = errors.New("booking already has a reschedule waiting for approval")
+	ErrNoPendingReschedule = errors.New("booking has no reschedule waiting for approval")
+	ErrRescheduleTooLate   = errors.New("cannot reschedule booking less than 1 hour before start time")
+)
+
+// Reschedule events passed to reschedule hooks
+const (
+	RescheduleRequested = "requested"
+	RescheduleApplied   = "applied"
+	RescheduleDeclined  = "declined"
+)
+
+type RescheduleBookingRequest struct {
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Reason    string    `json:"reason"`
+}
+
+// BookingRescheduleEvent is passed to reschedule hooks inside the
+// transaction that recorded it.
+type BookingRescheduleEvent struct {
+	Event      string
+	Booking    *models.Booking
+	Reschedule *models.BookingReschedule
+	Actor      Actor
+	Role       string
+}
+
+// RescheduleHook runs inside the transaction of a reschedule request or
+// decision. Returning an error rolls it back.
+type RescheduleHook func(tx *gorm.DB, e BookingRescheduleEvent) error
+
+// OnReschedule registers a hook that runs on every reschedule event.
+func (s *BookingService) OnReschedule(hook RescheduleHook) {
+	s.rescheduleHooks = append(s.rescheduleHooks, hook)
+}
+
+// RescheduleBooking moves a booking to a new time, keeping its ID. The new
+// slot is claimed straight away; the booking moves and the old slot is
+// released immediately, or on approval by the other party when
+// RescheduleRequiresApproval is set.
+func (s *BookingService) RescheduleBooking(bookingID uint, actor Actor, req RescheduleBookingRequest) (*models.BookingReschedule, error) {
+	var reschedule *models.BookingReschedule
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		reschedule, err = s.rescheduleBooking(tx, bookingID, actor, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, reschedule.Booking.ExpertID)
+
+	return reschedule, nil
+}
+
+// rescheduleBooking moves a booking inside the caller's transaction.
+func (s *BookingService) rescheduleBooking(tx *gorm.DB, bookingID uint, actor Actor, req RescheduleBookingRequest) (*models.BookingReschedule, error) {
+	req.StartTime = req.StartTime.UTC()
+	req.EndTime = req.EndTime.UTC()
+
+	if !req.EndTime.After(req.StartTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	booking, err := lockBooking(tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Authorize(actor, ActionReschedule, booking); err != nil {
+		return nil, err
+	}
+	role := bookingRole(booking, actor)
+
+	if booking.Status != models.BookingStatusPending && booking.Status != models.BookingStatusConfirmed {
+		return nil, ErrNotReschedulable
+	}
+
+	cutoff := time.Now().Add(changeCutoff)
+	if cutoff.After(booking.StartTime) || cutoff.After(req.StartTime) {
+		return nil, ErrRescheduleTooLate
+	}
+
+	if req.StartTime.Equal(booking.StartTime) && req.EndTime.Equal(booking.EndTime) {
+		return nil, errors.New("booking is already at this time")
+	}
+
+	var pending int64
+	if err := tx.Model(&models.BookingReschedule{}).
+		Where("booking_id = ? AND status = ?", booking.ID, models.RescheduleStatusPending).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrReschedulePending
+	}
+
+	slot, err := s.claimSlot(tx, booking.UserID, booking.ExpertID, req.StartTime, req.EndTime, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	reschedule := models.BookingReschedule{
+		BookingID:       booking.ID,
+		Booking:         booking,
+		RequestedBy:     actor.UserID,
+		RequestedByRole: role,
+		OldStartTime:    booking.StartTime,
+		OldEndTime:      booking.EndTime,
+		OldSlotID:       booking.SlotID,
+		NewStartTime:    req.StartTime,
+		NewEndTime:      req.EndTime,
+		NewSlotID:       &slot.ID,
+		Status:          models.RescheduleStatusPending,
+		Reason:          req.Reason,
+	}
+	if err := tx.Omit("Booking").Create(&reschedule).Error; err != nil {
+		return nil, err
+	}
+
+	// Admins move bookings without asking
+	if s.policy.RescheduleRequiresApproval && role != ActorRoleAdmin {
+		err = s.runRescheduleHooks(tx, RescheduleRequested, &reschedule, actor, role)
+	} else {
+		err = s.applyReschedule(tx, &reschedule, actor, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reschedule, nil
+}
+
+// ApproveReschedule applies the booking's pending reschedule. Only the
+// party that didn't ask for it, or an admin, may approve.
+func (s *BookingService) ApproveReschedule(bookingID uint, actor Actor) (*models.BookingReschedule, error) {
+	return s.decideReschedule(bookingID, actor, true)
+}
+
+// DeclineReschedule drops the booking's pending reschedule and frees the
+// slot it was holding. The requester declining it withdraws the request.
+func (s *BookingService) DeclineReschedule(bookingID uint, actor Actor) (*models.BookingReschedule, error) {
+	return s.decideReschedule(bookingID, actor, false)
+}
+
+func (s *BookingService) decideReschedule(bookingID uint, actor Actor, approve bool) (*models.BookingReschedule, error) {
+	var reschedule models.BookingReschedule
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		booking, err := lockBooking(tx, bookingID)
+		if err != nil {
+			return err
+		}
+
+		if err := Authorize(actor, ActionReschedule, booking); err != nil {
+			return err
+		}
+		role := bookingRole(booking, actor)
+
+		if err := tx.Where("booking_id = ? AND status = ?", booking.ID, models.RescheduleStatusPending).
+			First(&reschedule).Error; err != nil {
+			return ErrNoPendingReschedule
+		}
+		reschedule.Booking = booking
+
+		if !approve {
+			if err := releaseRescheduleSlot(tx, booking, &reschedule); err != nil {
+				return err
+			}
+			if err := decideRescheduleStatus(tx, &reschedule, models.RescheduleStatusDeclined, actor); err != nil {
+				return err
+			}
+			return s.runRescheduleHooks(tx, RescheduleDeclined, &reschedule, actor, role)
+		}
+
+		if role != ActorRoleAdmin && role == reschedule.RequestedByRole {
+			return ErrForbidden
+		}
+		if time.Now().Add(changeCutoff).After(reschedule.NewStartTime) {
+			return ErrRescheduleTooLate
+		}
+
+		return s.applyReschedule(tx, &reschedule, actor, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, reschedule.Booking.ExpertID)
+
+	return &reschedule, nil
+}
+
+// GetReschedules returns the reschedule history of a booking to anyone
+// allowed to see the booking.
+func (s *BookingService) GetReschedules(bookingID uint, actor Actor) ([]models.BookingReschedule, error) {
+	var booking models.Booking
+	if err := s.db.Preload("Expert").First(&booking, bookingID).Error; err != nil {
+		return nil, ErrBookingNotFound
+	}
+
+	if err := Authorize(actor, ActionView, &booking); err != nil {
+		return nil, err
+	}
+
+	var reschedules []models.BookingReschedule
+	err := s.db.Where("booking_id = ?", bookingID).Order("created_at").Find(&reschedules).Error
+	return reschedules, err
+}
+
+// applyReschedule moves the booking to the reschedule's time and slot and
+// releases the slot it held before.
+func (s *BookingService) applyReschedule(tx *gorm.DB, reschedule *models.BookingReschedule, actor Actor, role string) error {
+	booking := reschedule.Booking
+
+	// The booking may have moved within the slot it already held
+	if booking.SlotID == nil || *booking.SlotID != *reschedule.NewSlotID {
+		if err := releaseSlot(tx, booking); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(booking).Updates(map[string]interface{}{
+		"start_time": reschedule.NewStartTime,
+		"end_time":   reschedule.NewEndTime,
+		"slot_id":    reschedule.NewSlotID,
+	}).Error; err != nil {
+		return err
+	}
+	booking.StartTime = reschedule.NewStartTime
+	booking.EndTime = reschedule.NewEndTime
+	booking.SlotID = reschedule.NewSlotID
+
+	if err := decideRescheduleStatus(tx, reschedule, models.RescheduleStatusApplied, actor); err != nil {
+		return err
+	}
+	return s.runRescheduleHooks(tx, RescheduleApplied, reschedule, actor, role)
+}
+
+func (s *BookingService) runRescheduleHooks(tx *gorm.DB, event string, reschedule *models.BookingReschedule, actor Actor, role string) error {
+	e := BookingRescheduleEvent{
+		Event:      event,
+		Booking:    reschedule.Booking,
+		Reschedule: reschedule,
+		Actor:      actor,
+		Role:       role,
+	}
+	for _, hook := range s.rescheduleHooks {
+		if err := hook(tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decideRescheduleStatus(tx *gorm.DB, reschedule *models.BookingReschedule, status string, actor Actor) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     status,
+		"decided_at": now,
+	}
+	if actor.UserID != 0 {
+		updates["decided_by"] = actor.UserID
+		reschedule.DecidedBy = &actor.UserID
+	}
+	if err := tx.Model(&models.BookingReschedule{}).Where("id = ?", reschedule.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	reschedule.Status = status
+	reschedule.DecidedAt = &now
+	return nil
+}
+
+// releaseRescheduleSlot frees the seat claimed by a pending reschedule
+// unless it is the one the booking still holds.
+func releaseRescheduleSlot(tx *gorm.DB, booking *models.Booking, reschedule *models.BookingReschedule) error {
+	if reschedule.NewSlotID == nil || (booking.SlotID != nil && *booking.SlotID == *reschedule.NewSlotID) {
+		return nil
+	}
+	return releaseSeat(tx, *reschedule.NewSlotID)
+}
+
+// closeReschedulesHook drops pending reschedules of a booking that is no
+// longer pending or confirmed, freeing the slots they held.
+func closeReschedulesHook(tx *gorm.DB, t BookingTransition) error {
+	if t.To == models.BookingStatusPending || t.To == models.BookingStatusConfirmed {
+		return nil
+	}
+
+	var pending []models.BookingReschedule
+	if err := tx.Where("booking_id = ? AND status = ?", t.Booking.ID, models.RescheduleStatusPending).
+		Find(&pending).Error; err != nil {
+		return err
+	}
+
+	for i := range pending {
+		if err := releaseRescheduleSlot(tx, t.Booking, &pending[i]); err != nil {
+			return err
+		}
+		if err := decideRescheduleStatus(tx, &pending[i], models.RescheduleStatusCancelled, t.Actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
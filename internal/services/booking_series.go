@@ -0,0 +1,419 @@
+// internal/services/booking_series.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSeriesNotFound         = errors.New("booking series not found")
+	ErrNotInSeries            = errors.New("booking is not part of a series")
+	ErrInvalidSeriesFrequency = errors.New("frequency must be weekly or biweekly")
+	ErrInvalidSeriesMode      = errors.New("mode must be all_or_nothing or skip_conflicts")
+	ErrInvalidSeriesScope     = errors.New("scope must be this, following or all")
+	ErrNoOccurrencesToChange  = errors.New("no upcoming occurrences to change")
+	ErrSeriesShiftOverlaps    = errors.New("occurrences would move onto times other occurrences hold until the reschedule is approved")
+)
+
+// Which occurrences a series change applies to, counted from the booking
+// it is made on
+const (
+	SeriesScopeThis      = "this"
+	SeriesScopeFollowing = "following"
+	SeriesScopeAll       = "all"
+)
+
+// seriesWeeks is the number of weeks between occurrences per frequency.
+var seriesWeeks = map[string]int{
+	models.SeriesFrequencyWeekly:   1,
+	models.SeriesFrequencyBiweekly: 2,
+}
+
+type CreateSeriesRequest struct {
+	ExpertID      uint      `json:"expert_id" binding:"required"`
+	SessionTypeID *uint     `json:"session_type_id"`
+	StartTime     time.Time `json:"start_time" binding:"required"` // first occurrence
+	EndTime       time.Time `json:"end_time" binding:"required"`
+	Frequency     string    `json:"frequency" binding:"required"` // weekly, biweekly
+	Occurrences   int       `json:"occurrences" binding:"required,min=2,max=52"`
+	Mode          string    `json:"mode"` // all_or_nothing (default), skip_conflicts
+	Notes         string    `json:"notes"`
+	Format        string    `json:"format"` // online, offline
+}
+
+type CancelSeriesRequest struct {
+	Scope  string `json:"scope" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type RescheduleSeriesRequest struct {
+	Scope string `json:"scope" binding:"required"`
+	RescheduleBookingRequest
+}
+
+// SkippedOccurrence is an occurrence left out of a series booked in
+// skip_conflicts mode.
+type SkippedOccurrence struct {
+	Index     int       `json:"index"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+// SeriesResult is a newly booked series with the occurrences it skipped.
+type SeriesResult struct {
+	Series  *models.BookingSeries `json:"series"`
+	Skipped []SkippedOccurrence   `json:"skipped"`
+}
+
+// SeriesOccurrenceError is a series operation refused because one of its
+// occurrences couldn't be booked or changed. Nothing was written.
+type SeriesOccurrenceError struct {
+	Index     int
+	StartTime time.Time
+	Err       error
+}
+
+func (e *SeriesOccurrenceError) Error() string {
+	return fmt.Sprintf("occurrence %d on %s: %s", e.Index, e.StartTime.Format(time.RFC3339), e.Err)
+}
+
+func (e *SeriesOccurrenceError) Unwrap() error {
+	return e.Err
+}
+
+// CreateSeries books a recurring series. Occurrences keep the wall-clock
+// time of the first one in the expert's zone, like slots generated from
+// availability rules. In all_or_nothing mode one unavailable occurrence
+// refuses the whole series; in skip_conflicts mode it is left out and
+// reported.
+func (s *BookingService) CreateSeries(userID uint, req CreateSeriesRequest) (*SeriesResult, error) {
+	weeks, ok := seriesWeeks[req.Frequency]
+	if !ok {
+		return nil, ErrInvalidSeriesFrequency
+	}
+
+	if req.Mode == "" {
+		req.Mode = models.SeriesModeAllOrNothing
+	}
+	if req.Mode != models.SeriesModeAllOrNothing && req.Mode != models.SeriesModeSkipConflicts {
+		return nil, ErrInvalidSeriesMode
+	}
+
+	if !req.EndTime.After(req.StartTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	var expert models.Expert
+	if err := s.db.First(&expert, req.ExpertID).Error; err != nil {
+		return nil, ErrExpertNotFound
+	}
+	first := req.StartTime.In(locationOrUTC(expert.Timezone))
+	duration := req.EndTime.Sub(req.StartTime)
+
+	series := models.BookingSeries{
+		UserID:        userID,
+		ExpertID:      req.ExpertID,
+		Frequency:     req.Frequency,
+		Occurrences:   req.Occurrences,
+		StartTime:     req.StartTime.UTC(),
+		EndTime:       req.EndTime.UTC(),
+		Mode:          req.Mode,
+		SessionTypeID: req.SessionTypeID,
+		Status:        models.SeriesStatusActive,
+		Notes:         req.Notes,
+		Format:        req.Format,
+	}
+	skipped := []SkippedOccurrence{}
+	var booked []uint
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&series).Error; err != nil {
+			return err
+		}
+
+		skip := req.Mode == models.SeriesModeSkipConflicts
+		var firstErr *SeriesOccurrenceError
+		for i := 0; i < req.Occurrences; i++ {
+			start := first.AddDate(0, 0, 7*weeks*i)
+			occurrence := CreateBookingRequest{
+				ExpertID:      req.ExpertID,
+				SessionTypeID: req.SessionTypeID,
+				StartTime:     start,
+				EndTime:       start.Add(duration),
+				Notes:         req.Notes,
+				Format:        req.Format,
+			}
+
+			// A failed occurrence is rolled back on its own when skipping
+			if skip {
+				if err := tx.SavePoint("occurrence").Error; err != nil {
+					return err
+				}
+			}
+
+			booking, err := s.reserveSlot(tx, userID, occurrence)
+			if err == nil {
+				err = tx.Model(booking).Updates(map[string]interface{}{
+					"series_id":    series.ID,
+					"series_index": i + 1,
+				}).Error
+			}
+			if err == nil {
+				booked = append(booked, *booking.SlotID)
+				continue
+			}
+
+			occurrenceErr := &SeriesOccurrenceError{Index: i + 1, StartTime: start.UTC(), Err: err}
+			if !skip {
+				return occurrenceErr
+			}
+			if err := tx.RollbackTo("occurrence").Error; err != nil {
+				return err
+			}
+			if firstErr == nil {
+				firstErr = occurrenceErr
+			}
+			skipped = append(skipped, SkippedOccurrence{
+				Index:     i + 1,
+				StartTime: start.UTC(),
+				EndTime:   start.Add(duration).UTC(),
+				Reason:    err.Error(),
+			})
+		}
+
+		// Skipping every occurrence leaves nothing worth keeping
+		if len(booked) == 0 && firstErr != nil {
+			return firstErr
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, series.ExpertID)
+
+	// The checkouts are over
+	for _, slotID := range booked {
+		s.ReleaseHold(userID, slotID)
+	}
+
+	loaded, err := s.loadSeries(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &SeriesResult{Series: loaded, Skipped: skipped}, nil
+}
+
+// GetSeries returns a series and its occurrences to its client, its expert
+// or an admin.
+func (s *BookingService) GetSeries(seriesID uint, actor Actor) (*models.BookingSeries, error) {
+	series, err := s.loadSeries(seriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Authorize(actor, ActionView, series); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// CancelSeries cancels the booking, the booking and the following
+// occurrences, or every upcoming occurrence of its series. Each one goes
+// through the expert's cancellation policy; if any is refused nothing is
+// cancelled. The client's monthly limit is checked once for the whole
+// change.
+func (s *BookingService) CancelSeries(bookingID uint, actor Actor, req CancelSeriesRequest) ([]models.Booking, error) {
+	var cancelled []models.Booking
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		_, occurrences, err := s.lockSeriesOccurrences(tx, bookingID, actor, ActionCancel, req.Scope)
+		if err != nil {
+			return err
+		}
+
+		for i, occurrence := range occurrences {
+			booking, err := s.cancelBooking(tx, occurrence.ID, actor, req.Reason, i == 0)
+			if err != nil {
+				return &SeriesOccurrenceError{Index: occurrence.SeriesIndex, StartTime: occurrence.StartTime, Err: err}
+			}
+			cancelled = append(cancelled, *booking)
+		}
+
+		if req.Scope == SeriesScopeAll {
+			return tx.Model(&models.BookingSeries{}).
+				Where("id = ?", *occurrences[0].SeriesID).
+				Update("status", models.SeriesStatusCancelled).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, cancelled[0].ExpertID)
+
+	return cancelled, nil
+}
+
+// RescheduleSeries moves the booking, the booking and the following
+// occurrences, or every upcoming occurrence of its series. The others move
+// the way the booking does: by the same number of days, to the new time of
+// day in the expert's zone. If any move is refused nothing moves.
+func (s *BookingService) RescheduleSeries(bookingID uint, actor Actor, req RescheduleSeriesRequest) ([]models.BookingReschedule, error) {
+	if !req.EndTime.After(req.StartTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	var reschedules []models.BookingReschedule
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		ref, occurrences, err := s.lockSeriesOccurrences(tx, bookingID, actor, ActionReschedule, req.Scope)
+		if err != nil {
+			return err
+		}
+
+		loc := locationOrUTC(ref.Expert.Timezone)
+		shift := seriesShift(ref.StartTime, req.StartTime, loc)
+		duration := req.EndTime.Sub(req.StartTime)
+
+		// Reschedules waiting for approval leave the occurrences where they
+		// are, so none may move onto another's time
+		if s.policy.RescheduleRequiresApproval && bookingRole(ref, actor) != ActorRoleAdmin {
+			if err := checkSeriesShiftOverlaps(occurrences, shift, duration); err != nil {
+				return err
+			}
+		}
+
+		// Move the occurrence furthest along first, so none lands on a
+		// time another one still holds
+		forward := req.StartTime.After(ref.StartTime)
+		for i := range occurrences {
+			occurrence := occurrences[i]
+			if forward {
+				occurrence = occurrences[len(occurrences)-1-i]
+			}
+
+			start := shift(occurrence.StartTime)
+			reschedule, err := s.rescheduleBooking(tx, occurrence.ID, actor, RescheduleBookingRequest{
+				StartTime: start,
+				EndTime:   start.Add(duration),
+				Reason:    req.Reason,
+			})
+			if err != nil {
+				return &SeriesOccurrenceError{Index: occurrence.SeriesIndex, StartTime: occurrence.StartTime, Err: err}
+			}
+			reschedules = append(reschedules, *reschedule)
+		}
+
+		if forward {
+			for i, j := 0, len(reschedules)-1; i < j; i, j = i+1, j-1 {
+				reschedules[i], reschedules[j] = reschedules[j], reschedules[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, reschedules[0].Booking.ExpertID)
+
+	return reschedules, nil
+}
+
+// checkSeriesShiftOverlaps refuses a shift that moves an occurrence onto
+// the current time of another occurrence being moved.
+func checkSeriesShiftOverlaps(occurrences []models.Booking, shift func(time.Time) time.Time, duration time.Duration) error {
+	for _, moving := range occurrences {
+		start := shift(moving.StartTime)
+		end := start.Add(duration)
+		for _, held := range occurrences {
+			if held.ID != moving.ID && start.Before(held.EndTime) && end.After(held.StartTime) {
+				return &SeriesOccurrenceError{Index: moving.SeriesIndex, StartTime: moving.StartTime, Err: ErrSeriesShiftOverlaps}
+			}
+		}
+	}
+	return nil
+}
+
+// lockSeriesOccurrences locks a booking and its series and returns the
+// booking with the occurrences a change in scope applies to, in series
+// order. Scope this selects the booking alone, whatever its status.
+// Following and all select the upcoming pending or confirmed occurrences
+// of the series, from the booking's index onwards for following; the
+// booking itself is among them only if it is one of those.
+func (s *BookingService) lockSeriesOccurrences(tx *gorm.DB, bookingID uint, actor Actor, action, scope string) (*models.Booking, []models.Booking, error) {
+	if scope != SeriesScopeThis && scope != SeriesScopeFollowing && scope != SeriesScopeAll {
+		return nil, nil, ErrInvalidSeriesScope
+	}
+
+	booking, err := lockBooking(tx, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := Authorize(actor, action, booking); err != nil {
+		return nil, nil, err
+	}
+	if booking.SeriesID == nil {
+		return nil, nil, ErrNotInSeries
+	}
+
+	var series models.BookingSeries
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, *booking.SeriesID).Error; err != nil {
+		return nil, nil, ErrSeriesNotFound
+	}
+
+	if scope == SeriesScopeThis {
+		return booking, []models.Booking{*booking}, nil
+	}
+
+	query := tx.Where("series_id = ? AND status IN ? AND start_time > ?",
+		series.ID, []string{models.BookingStatusPending, models.BookingStatusConfirmed}, time.Now())
+	if scope == SeriesScopeFollowing {
+		query = query.Where("series_index >= ?", booking.SeriesIndex)
+	}
+
+	var occurrences []models.Booking
+	if err := query.Order("series_index").Find(&occurrences).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(occurrences) == 0 {
+		return nil, nil, ErrNoOccurrencesToChange
+	}
+	return booking, occurrences, nil
+}
+
+func (s *BookingService) loadSeries(seriesID uint) (*models.BookingSeries, error) {
+	var series models.BookingSeries
+	if err := s.db.Preload("User").Preload("Expert").Preload("Expert.User").
+		Preload("Bookings", func(db *gorm.DB) *gorm.DB {
+			return db.Order("series_index")
+		}).
+		First(&series, seriesID).Error; err != nil {
+		return nil, ErrSeriesNotFound
+	}
+	return &series, nil
+}
+
+// seriesShift returns a function moving an occurrence the way from moves
+// to to: by the same number of calendar days, to the new time of day in
+// loc.
+func seriesShift(from, to time.Time, loc *time.Location) func(time.Time) time.Time {
+	from = from.In(loc)
+	to = to.In(loc)
+	days := int(civilDate(to).Sub(civilDate(from)).Hours() / 24)
+
+	return func(start time.Time) time.Time {
+		day := start.In(loc).AddDate(0, 0, days)
+		return time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), to.Second(), 0, loc)
+	}
+}
+
+// civilDate is the calendar date of t, as midnight UTC.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,85 @@
+// internal/services/booking_series_test.go
+package services
+
+import (
+	"consultation-booking/internal/config"
+	"consultation-booking/internal/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+const week = 7 * 24 * time.Hour
+
+func TestRescheduleSeriesWithApproval(t *testing.T) {
+	env := newTestEnv(t)
+	env.bookings = NewBookingService(env.db, env.redis, config.BookingConfig{RescheduleRequiresApproval: true})
+	_, expert := env.createExpert(t)
+	client := env.createUser(t, "user")
+	admin := env.createUser(t, "admin")
+
+	start := futureHour(48)
+	for i := 0; i < 4; i++ {
+		env.createSlot(t, expert.ID, start.Add(time.Duration(i)*week), time.Hour)
+		env.createSlot(t, expert.ID, start.Add(time.Duration(i)*week+24*time.Hour), time.Hour)
+	}
+
+	result, err := env.bookings.CreateSeries(client.ID, CreateSeriesRequest{
+		ExpertID:    expert.ID,
+		StartTime:   start,
+		EndTime:     start.Add(time.Hour),
+		Frequency:   models.SeriesFrequencyWeekly,
+		Occurrences: 3,
+	})
+	if err != nil {
+		t.Fatalf("CreateSeries: %v", err)
+	}
+	first := result.Series.Bookings[0]
+
+	shiftBy := func(actor Actor, d time.Duration) ([]models.BookingReschedule, error) {
+		return env.bookings.RescheduleSeries(first.ID, actor, RescheduleSeriesRequest{
+			Scope: SeriesScopeAll,
+			RescheduleBookingRequest: RescheduleBookingRequest{
+				StartTime: first.StartTime.Add(d),
+				EndTime:   first.EndTime.Add(d),
+			},
+		})
+	}
+
+	// A week later each occurrence lands on the next one's time, which it
+	// keeps until the reschedule is approved
+	if _, err := shiftBy(actorFor(client), week); !errors.Is(err, ErrSeriesShiftOverlaps) {
+		t.Fatalf("shifting by a week: got %v, want ErrSeriesShiftOverlaps", err)
+	}
+	var pending int64
+	env.db.Model(&models.BookingReschedule{}).Count(&pending)
+	if pending != 0 {
+		t.Fatalf("refused shift left %d reschedules", pending)
+	}
+
+	// Admins move the series straight away, furthest occurrence first
+	applied, err := shiftBy(actorFor(admin), week)
+	if err != nil {
+		t.Fatalf("admin shifting by a week: %v", err)
+	}
+	for _, r := range applied {
+		if r.Status != models.RescheduleStatusApplied {
+			t.Fatalf("admin reschedule %d is %q, want applied", r.ID, r.Status)
+		}
+	}
+
+	first.StartTime = first.StartTime.Add(week)
+	first.EndTime = first.EndTime.Add(week)
+	requested, err := shiftBy(actorFor(client), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("shifting by a day: %v", err)
+	}
+	if len(requested) != 3 {
+		t.Fatalf("%d reschedules requested, want 3", len(requested))
+	}
+	for _, r := range requested {
+		if r.Status != models.RescheduleStatusPending {
+			t.Fatalf("reschedule %d is %q, want pending", r.ID, r.Status)
+		}
+	}
+}
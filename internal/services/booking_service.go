@@ -2,304 +2,611 @@
 package services
 
 import (
+	"consultation-booking/internal/config"
 	"consultation-booking/internal/models"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var (
+	ErrExpertNotFound   = errors.New("expert not found")
+	ErrUserConflict     = errors.New("you have a conflicting booking at this time")
+	ErrExpertConflict   = errors.New("expert has a conflicting booking at this time")
+	ErrSlotUnavailable  = errors.New("time slot is not available")
+	ErrSlotTaken        = errors.New("time slot is already taken")
+	ErrGroupSessionTime = errors.New("group sessions must be booked for their full time")
+	ErrEmailNotVerified = errors.New("please verify your email address before booking")
+)
+
+// Bookings can't be moved closer than this to their start
+const changeCutoff = time.Hour
+
 type BookingService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db     *gorm.DB
+	redis  *redis.Client
+	policy config.BookingConfig
+	hooks  []TransitionHook
+
+	rescheduleHooks []RescheduleHook
 }
 
 type CreateBookingRequest struct {
-	ExpertID  uint      `json:"expert_id" binding:"required"`
-	StartTime time.Time `json:"start_time" binding:"required"`
-	EndTime   time.Time `json:"end_time" binding:"required"`
-	Notes     string    `json:"notes"`
-	Format    string    `json:"format"` // online, offline
+	ExpertID      uint      `json:"expert_id" binding:"required"`
+	SessionTypeID *uint     `json:"session_type_id"`
+	StartTime     time.Time `json:"start_time" binding:"required"`
+	EndTime       time.Time `json:"end_time"` // optional with a session type
+	Notes         string    `json:"notes"`
+	Format        string    `json:"format"` // online, offline
 }
 
-func NewBookingService(db *gorm.DB, redis *redis.Client) *BookingService {
-	return &BookingService{
-		db:    db,
-		redis: redis,
+func NewBookingService(db *gorm.DB, redis *redis.Client, policy config.BookingConfig) *BookingService {
+	s := &BookingService{
+		db:     db,
+		redis:  redis,
+		policy: policy,
 	}
+	s.OnTransition(releaseSlotHook)
+	s.OnTransition(closeReschedulesHook)
+	return s
+}
+
+// OnTransition registers a hook that runs on every booking status change.
+func (s *BookingService) OnTransition(hook TransitionHook) {
+	s.hooks = append(s.hooks, hook)
 }
 
 func (s *BookingService) CreateBooking(userID uint, req CreateBookingRequest) (*models.Booking, error) {
-	// Check if expert exists and is available
-	var expert models.Expert
-	if err := s.db.First(&expert, req.ExpertID).Error; err != nil {
-		return nil, errors.New("expert not found")
+	var booking *models.Booking
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		booking, err = s.reserveSlot(tx, userID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	invalidateSlotCache(s.redis, booking.ExpertID)
 
-	if !expert.IsAvailable {
-		return nil, errors.New("expert is not available")
+	// The checkout is over
+	s.ReleaseHold(userID, *booking.SlotID)
+
+	// Load relationships
+	s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(booking, booking.ID)
+
+	return booking, nil
+}
+
+// reserveSlot creates a pending booking and claims the matching slot. It
+// must run inside a transaction: the client and expert rows are locked
+// first so concurrent reservations for either of them are serialized, then
+// the slot row itself is locked before it is flipped.
+func (s *BookingService) reserveSlot(tx *gorm.DB, userID uint, req CreateBookingRequest) (*models.Booking, error) {
+	// Times arrive with explicit offsets and are stored in UTC
+	req.StartTime = req.StartTime.UTC()
+	req.EndTime = req.EndTime.UTC()
+
+	// A session type sets the length and price of the session
+	var sessionType *models.SessionType
+	if req.SessionTypeID != nil {
+		var err error
+		if sessionType, err = findSessionType(tx, req.ExpertID, *req.SessionTypeID); err != nil {
+			return nil, err
+		}
+
+		end := req.StartTime.Add(time.Duration(sessionType.DurationMinutes) * time.Minute)
+		if !req.EndTime.IsZero() && !req.EndTime.Equal(end) {
+			return nil, ErrSessionTypeDuration
+		}
+		req.EndTime = end
 	}
 
-	// Check for conflicts - user shouldn't have overlapping bookings
-	var userConflictCount int64
-	s.db.Model(&models.Booking{}).Where(
-		"user_id = ? AND status NOT IN (?, ?) AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?))",
-		userID, "cancelled", "completed", req.StartTime, req.StartTime, req.EndTime, req.EndTime,
-	).Count(&userConflictCount)
+	if !req.EndTime.After(req.StartTime) {
+		return nil, errors.New("end time must be after start time")
+	}
 
-	if userConflictCount > 0 {
-		return nil, errors.New("you have a conflicting booking at this time")
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
 	}
 
-	// Check for expert conflicts
-	var expertConflictCount int64
-	s.db.Model(&models.Booking{}).Where(
-		"expert_id = ? AND status NOT IN (?, ?) AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?))",
-		req.ExpertID, "cancelled", "completed", req.StartTime, req.StartTime, req.EndTime, req.EndTime,
-	).Count(&expertConflictCount)
+	if s.policy.RequireVerifiedEmail && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
 
-	if expertConflictCount > 0 {
-		return nil, errors.New("expert has a conflicting booking at this time")
+	// Check if expert exists and is available
+	var expert models.Expert
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&expert, req.ExpertID).Error; err != nil {
+		return nil, ErrExpertNotFound
+	}
+
+	if !expert.IsAvailable {
+		return nil, errors.New("expert is not available")
 	}
 
-	// Check if the time slot is available
-	var availableSlot models.AvailableSlot
-	if err := s.db.Where(
-		"expert_id = ? AND start_time <= ? AND end_time >= ? AND is_booked = ?",
-		req.ExpertID, req.StartTime, req.EndTime, false,
-	).First(&availableSlot).Error; err != nil {
-		return nil, errors.New("time slot is not available")
+	slot, err := s.claimSlot(tx, userID, req.ExpertID, req.StartTime, req.EndTime, nil)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create booking
 	booking := models.Booking{
 		UserID:    userID,
 		ExpertID:  req.ExpertID,
+		SlotID:    &slot.ID,
 		StartTime: req.StartTime,
 		EndTime:   req.EndTime,
-		Status:    "pending",
+		Status:    models.BookingStatusPending,
 		Notes:     req.Notes,
 		Format:    req.Format,
 	}
+	if sessionType != nil {
+		booking.SessionTypeID = &sessionType.ID
+		booking.PriceCents = sessionType.PriceCents
+		booking.Currency = sessionType.Currency
+	}
 
-	if err := s.db.Create(&booking).Error; err != nil {
+	if err := tx.Create(&booking).Error; err != nil {
 		return nil, err
 	}
 
-	// Mark slot as booked
-	s.db.Model(&models.AvailableSlot{}).Where("id = ?", availableSlot.ID).Update("is_booked", true)
-
-	// Load relationships
-	s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(&booking, booking.ID)
-
 	return &booking, nil
 }
 
-func (s *BookingService) GetBooking(bookingID uint) (*models.Booking, error) {
-	var booking models.Booking
-	err := s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(&booking, bookingID).Error
-	return &booking, err
-}
+// claimSlot checks that neither the client nor the expert is busy between
+// start and end, then locks the slot covering that time and takes one of
+// its seats, carving the session out of longer one-to-one slots. When
+// moving an existing booking, that booking is left out of the conflict
+// checks and its own slot may be reused.
+func (s *BookingService) claimSlot(tx *gorm.DB, userID, expertID uint, start, end time.Time, moving *models.Booking) (*models.AvailableSlot, error) {
+	var excludeID uint
+	if moving != nil {
+		excludeID = moving.ID
+	}
 
-func (s *BookingService) CancelBooking(bookingID uint, userID uint, reason string) error {
-	var booking models.Booking
-	if err := s.db.First(&booking, bookingID).Error; err != nil {
-		return errors.New("booking not found")
+	// Check for conflicts - user shouldn't have overlapping bookings
+	var userConflictCount int64
+	if err := tx.Model(&models.Booking{}).Where(
+		"user_id = ? AND id <> ? AND status IN (?) AND start_time < ? AND end_time > ?",
+		userID, excludeID, activeBookingStatuses, end, start,
+	).Count(&userConflictCount).Error; err != nil {
+		return nil, err
 	}
 
-	// Check if user owns the booking or is the expert
-	if booking.UserID != userID && booking.Expert.UserID != userID {
-		return errors.New("unauthorized to cancel this booking")
+	if userConflictCount > 0 {
+		return nil, ErrUserConflict
 	}
 
-	// Check if cancellation is allowed (at least 1 hour before)
-	if time.Now().Add(time.Hour).After(booking.StartTime) {
-		return errors.New("cannot cancel booking less than 1 hour before start time")
+	// Lock the slot covering the requested time
+	var slot models.AvailableSlot
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(
+		"expert_id = ? AND start_time <= ? AND end_time >= ?",
+		expertID, start, end,
+	).Order("is_booked").First(&slot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		joined, joinErr := joinFreeSlots(tx, expertID, start, end)
+		if joinErr != nil {
+			return nil, joinErr
+		}
+		slot = *joined
+	} else if err != nil {
+		return nil, ErrSlotUnavailable
 	}
 
-	// Update booking status
-	updates := map[string]interface{}{
-		"status":        "cancelled",
-		"cancel_reason": reason,
+	// Check for expert conflicts - attendees of the same group session
+	// share the expert's time
+	var expertConflictCount int64
+	if err := tx.Model(&models.Booking{}).Where(
+		"expert_id = ? AND id <> ? AND (slot_id IS NULL OR slot_id <> ?) AND status IN (?) AND start_time < ? AND end_time > ?",
+		expertID, excludeID, slot.ID, activeBookingStatuses, end, start,
+	).Count(&expertConflictCount).Error; err != nil {
+		return nil, err
 	}
 
-	if err := s.db.Model(&booking).Updates(updates).Error; err != nil {
-		return err
+	if expertConflictCount > 0 {
+		return nil, ErrExpertConflict
 	}
 
-	// Free up the slot
-	s.db.Model(&models.AvailableSlot{}).Where(
-		"expert_id = ? AND start_time <= ? AND end_time >= ?",
-		booking.ExpertID, booking.StartTime, booking.EndTime,
-	).Update("is_booked", false)
+	settings, err := loadExpertSettings(tx, expertID, s.policy.Scheduling)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchedulingRules(tx, settings, &slot, start, end, excludeID); err != nil {
+		return nil, err
+	}
 
-	return nil
-}
+	// Moving within the slot the booking already holds
+	if moving != nil && moving.SlotID != nil && *moving.SlotID == slot.ID {
+		return &slot, nil
+	}
 
-func (s *BookingService) UpdateBookingStatus(bookingID uint, status string) error {
-	return s.db.Model(&models.Booking{}).Where("id = ?", bookingID).Update("status", status).Error
-}
+	// Group sessions are joined as a whole
+	if slot.Capacity > 1 && (!start.Equal(slot.StartTime) || !end.Equal(slot.EndTime)) {
+		return nil, ErrGroupSessionTime
+	}
 
-func (s *BookingService) GetUpcomingBookings() ([]models.Booking, error) {
-	var bookings []models.Booking
-	err := s.db.Where("start_time > ? AND start_time < ? AND status IN (?)", 
-		time.Now(), time.Now().Add(time.Hour*2), []string{"pending", "confirmed"}).
-		Preload("User").
-		Preload("Expert").
-		Preload("Expert.User").
-		Find(&bookings).Error
-	return bookings, err
-}// internal/services/booking_service.go
-package services
+	if slot.IsBooked {
+		return nil, ErrSlotTaken
+	}
 
-import (
-	"consultation-booking/internal/models"
-	"errors"
-	"time"
+	// Seats held during other users' checkouts are theirs to book
+	if s.seatsHeldByOthers(slot.ID, userID) >= slot.Capacity-slot.BookedCount {
+		return nil, ErrSlotHeld
+	}
 
-	"github.com/go-redis/redis/v8"
-	"gorm.io/gorm"
-)
+	taken, err := takeSeat(tx, slot.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !taken {
+		return nil, ErrSlotTaken
+	}
 
-type BookingService struct {
-	db    *gorm.DB
-	redis *redis.Client
-}
+	slot.BookedCount++
+	slot.IsBooked = slot.BookedCount >= slot.Capacity
 
-type CreateBookingRequest struct {
-	ExpertID  uint      `json:"expert_id" binding:"required"`
-	StartTime time.Time `json:"start_time" binding:"required"`
-	EndTime   time.Time `json:"end_time" binding:"required"`
-	Notes     string    `json:"notes"`
-	Format    string    `json:"format"` // online, offline
+	// Sessions shorter than a one-to-one slot leave the rest bookable
+	if slot.Capacity == 1 && (start.After(slot.StartTime) || end.Before(slot.EndTime)) {
+		if err := carveSlot(tx, &slot, start, end); err != nil {
+			return nil, err
+		}
+	}
+	return &slot, nil
 }
 
-func NewBookingService(db *gorm.DB, redis *redis.Client) *BookingService {
-	return &BookingService{
-		db:    db,
-		redis: redis,
+// takeSeat books one seat of a slot, marking the slot booked when its last
+// seat goes. It reports false when the slot is already full.
+func takeSeat(tx *gorm.DB, slotID uint) (bool, error) {
+	result := tx.Model(&models.AvailableSlot{}).
+		Where("id = ? AND is_booked = ? AND booked_count < capacity", slotID, false).
+		Updates(map[string]interface{}{
+			"booked_count": gorm.Expr("booked_count + 1"),
+			"is_booked":    gorm.Expr("booked_count + 1 >= capacity"),
+		})
+	if result.Error != nil {
+		return false, result.Error
 	}
+	return result.RowsAffected > 0, nil
 }
 
-func (s *BookingService) CreateBooking(userID uint, req CreateBookingRequest) (*models.Booking, error) {
-	// Check if expert exists and is available
-	var expert models.Expert
-	if err := s.db.First(&expert, req.ExpertID).Error; err != nil {
-		return nil, errors.New("expert not found")
+// releaseSeat frees one seat of a slot.
+func releaseSeat(tx *gorm.DB, slotID uint) error {
+	return tx.Model(&models.AvailableSlot{}).Where("id = ?", slotID).Updates(seatReleased()).Error
+}
+
+func seatReleased() map[string]interface{} {
+	return map[string]interface{}{
+		"booked_count": gorm.Expr("CASE WHEN booked_count > 0 THEN booked_count - 1 ELSE 0 END"),
+		"is_booked":    false,
 	}
+}
 
-	if !expert.IsAvailable {
-		return nil, errors.New("expert is not available")
+// carveSlot shrinks a slot to the session booked in it and adds the time
+// left before and after as free slots of the same window, so the rest of
+// the window stays bookable.
+func carveSlot(tx *gorm.DB, slot *models.AvailableSlot, start, end time.Time) error {
+	windowStart, windowEnd := slot.StartTime, slot.EndTime
+	if slot.WindowStart != nil && slot.WindowEnd != nil {
+		windowStart, windowEnd = *slot.WindowStart, *slot.WindowEnd
 	}
 
-	// Check for conflicts - user shouldn't have overlapping bookings
-	var userConflictCount int64
-	s.db.Model(&models.Booking{}).Where(
-		"user_id = ? AND status NOT IN (?, ?) AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?))",
-		userID, "cancelled", "completed", req.StartTime, req.StartTime, req.EndTime, req.EndTime,
-	).Count(&userConflictCount)
+	var remnants []models.AvailableSlot
+	for _, r := range [][2]time.Time{{slot.StartTime, start}, {end, slot.EndTime}} {
+		if !r[1].After(r[0]) {
+			continue
+		}
+		remnants = append(remnants, models.AvailableSlot{
+			ExpertID:    slot.ExpertID,
+			StartTime:   r[0],
+			EndTime:     r[1],
+			Capacity:    1,
+			RuleID:      slot.RuleID,
+			OverrideID:  slot.OverrideID,
+			WindowStart: &windowStart,
+			WindowEnd:   &windowEnd,
+		})
+	}
 
-	if userConflictCount > 0 {
-		return nil, errors.New("you have a conflicting booking at this time")
+	if err := tx.Model(&models.AvailableSlot{}).Where("id = ?", slot.ID).Updates(map[string]interface{}{
+		"start_time":   start,
+		"end_time":     end,
+		"window_start": windowStart,
+		"window_end":   windowEnd,
+	}).Error; err != nil {
+		return err
+	}
+	if len(remnants) > 0 {
+		if err := tx.Create(&remnants).Error; err != nil {
+			return err
+		}
 	}
 
-	// Check for expert conflicts
-	var expertConflictCount int64
-	s.db.Model(&models.Booking{}).Where(
-		"expert_id = ? AND status NOT IN (?, ?) AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?))",
-		req.ExpertID, "cancelled", "completed", req.StartTime, req.StartTime, req.EndTime, req.EndTime,
-	).Count(&expertConflictCount)
+	slot.StartTime = start
+	slot.EndTime = end
+	slot.WindowStart = &windowStart
+	slot.WindowEnd = &windowEnd
+	return nil
+}
 
-	if expertConflictCount > 0 {
-		return nil, errors.New("expert has a conflicting booking at this time")
+// joinFreeSlots joins adjacent free one-to-one slots that together cover
+// start to end into one slot, so a session can span time left free by
+// earlier bookings or several sessions of a rule. The other slots are
+// deleted.
+func joinFreeSlots(tx *gorm.DB, expertID uint, start, end time.Time) (*models.AvailableSlot, error) {
+	var pieces []models.AvailableSlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(
+		"expert_id = ? AND (window_start IS NOT NULL OR rule_id IS NOT NULL OR override_id IS NOT NULL)"+
+			" AND capacity = ? AND is_booked = ? AND booked_count = ? AND start_time < ? AND end_time > ?",
+		expertID, 1, false, 0, end, start,
+	).Order("start_time").Find(&pieces).Error; err != nil {
+		return nil, err
 	}
-
-	// Check if the time slot is available
-	var availableSlot models.AvailableSlot
-	if err := s.db.Where(
-		"expert_id = ? AND start_time <= ? AND end_time >= ? AND is_booked = ?",
-		req.ExpertID, req.StartTime, req.EndTime, false,
-	).First(&availableSlot).Error; err != nil {
-		return nil, errors.New("time slot is not available")
+	if len(pieces) < 2 || pieces[0].StartTime.After(start) {
+		return nil, ErrSlotUnavailable
 	}
 
-	// Create booking
-	booking := models.Booking{
-		UserID:    userID,
-		ExpertID:  req.ExpertID,
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		Status:    "pending",
-		Notes:     req.Notes,
-		Format:    req.Format,
+	joined := pieces[0]
+	var absorbed []uint
+	for i := 1; i < len(pieces); i++ {
+		if !pieces[i].StartTime.Equal(joined.EndTime) || !joinable(&pieces[i-1], &pieces[i]) {
+			break
+		}
+		joined.EndTime = pieces[i].EndTime
+		absorbed = append(absorbed, pieces[i].ID)
+	}
+	if joined.EndTime.Before(end) {
+		return nil, ErrSlotUnavailable
 	}
 
-	if err := s.db.Create(&booking).Error; err != nil {
+	if err := tx.Delete(&models.AvailableSlot{}, absorbed).Error; err != nil {
 		return nil, err
 	}
+	if err := tx.Model(&models.AvailableSlot{}).Where("id = ?", joined.ID).Update("end_time", joined.EndTime).Error; err != nil {
+		return nil, err
+	}
+	return &joined, nil
+}
 
-	// Mark slot as booked
-	s.db.Model(&models.AvailableSlot{}).Where("id = ?", availableSlot.ID).Update("is_booked", true)
+// freePiece reports whether joinFreeSlots may join a slot with its
+// neighbours: an untaken one-to-one slot carved from a window or generated
+// by a rule or override.
+func freePiece(slot *models.AvailableSlot) bool {
+	fromAvailability := slot.WindowStart != nil || slot.RuleID != nil || slot.OverrideID != nil
+	return fromAvailability && slot.Capacity == 1 && !slot.IsBooked && slot.BookedCount == 0
+}
 
-	// Load relationships
-	s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(&booking, booking.ID)
+// joinable reports whether two adjacent free slots belong together: pieces
+// carved out of the same window, or sessions of the same rule or override.
+func joinable(a, b *models.AvailableSlot) bool {
+	if a.WindowStart != nil && b.WindowStart != nil && a.WindowStart.Equal(*b.WindowStart) {
+		return true
+	}
+	if a.RuleID != nil && b.RuleID != nil {
+		return *a.RuleID == *b.RuleID
+	}
+	return a.OverrideID != nil && b.OverrideID != nil && *a.OverrideID == *b.OverrideID
+}
 
+// GetBooking returns a booking to its client, its expert or an admin.
+func (s *BookingService) GetBooking(bookingID uint, actor Actor) (*models.Booking, error) {
+	var booking models.Booking
+	if err := s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(&booking, bookingID).Error; err != nil {
+		return nil, ErrBookingNotFound
+	}
+
+	if err := Authorize(actor, ActionView, &booking); err != nil {
+		return nil, err
+	}
 	return &booking, nil
 }
 
-func (s *BookingService) GetBooking(bookingID uint) (*models.Booking, error) {
-	var booking models.Booking
-	err := s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(&booking, bookingID).Error
-	return &booking, err
+// CancelBooking cancels a booking under the expert's cancellation policy.
+// Depending on the policy, cancelling within the cutoff is refused, recorded
+// as a no-show or flagged for a late fee.
+func (s *BookingService) CancelBooking(bookingID uint, actor Actor, reason string) (*models.Booking, error) {
+	var booking *models.Booking
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		booking, err = s.cancelBooking(tx, bookingID, actor, reason, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, booking.ExpertID)
+
+	return booking, nil
 }
 
-func (s *BookingService) CancelBooking(bookingID uint, userID uint, reason string) error {
-	var booking models.Booking
-	if err := s.db.First(&booking, bookingID).Error; err != nil {
-		return errors.New("booking not found")
+// cancelBooking cancels a booking inside the caller's transaction. The
+// client's monthly limit is only checked when checkLimit is set.
+func (s *BookingService) cancelBooking(tx *gorm.DB, bookingID uint, actor Actor, reason string, checkLimit bool) (*models.Booking, error) {
+	booking, err := lockBooking(tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Authorize(actor, ActionCancel, booking); err != nil {
+		return nil, err
 	}
+	role := bookingRole(booking, actor)
 
-	// Check if user owns the booking or is the expert
-	if booking.UserID != userID && booking.Expert.UserID != userID {
-		return errors.New("unauthorized to cancel this booking")
+	if err := CheckBookingTransition(booking.Status, models.BookingStatusCancelled, role); err != nil {
+		return nil, err
+	}
+
+	policy, err := loadCancellationPolicy(tx, booking.ExpertID, s.policy.Cancellation)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if cancellation is allowed (at least 1 hour before)
-	if time.Now().Add(time.Hour).After(booking.StartTime) {
-		return errors.New("cannot cancel booking less than 1 hour before start time")
+	now := time.Now()
+	if role == ActorRoleClient && checkLimit {
+		if err := checkCancellationLimit(tx, booking, policy, now); err != nil {
+			return nil, err
+		}
 	}
 
-	// Update booking status
+	to := models.BookingStatusCancelled
 	updates := map[string]interface{}{
-		"status":        "cancelled",
-		"cancel_reason": reason,
+		"cancelled_by": role,
+		"cancelled_at": now,
 	}
 
-	if err := s.db.Model(&booking).Updates(updates).Error; err != nil {
-		return err
+	if cutoff := cancellationCutoff(policy, role); now.Add(cutoff).After(booking.StartTime) {
+		// A late cancellation by the expert is never the client's
+		// no-show or fee
+		switch policy.LateCancellationOutcome {
+		case models.LateCancellationNoShow:
+			// The no-show is recorded on the expert's behalf, so it must be
+			// a move the expert could make. Unconfirmed bookings can't be
+			// missed and stay cancelled.
+			updates["late_cancellation"] = true
+			if role == ActorRoleClient &&
+				CheckBookingTransition(booking.Status, models.BookingStatusNoShow, ActorRoleExpert) == nil {
+				to = models.BookingStatusNoShow
+			}
+		case models.LateCancellationFee:
+			updates["late_cancellation"] = true
+			updates["late_fee_due"] = role == ActorRoleClient
+		default:
+			return nil, &CancellationError{
+				Code:   CodeCancellationTooLate,
+				Err:    ErrCancellationTooLate,
+				Detail: fmt.Sprintf("bookings must be cancelled at least %d minutes before the start", int(cutoff.Minutes())),
+			}
+		}
 	}
 
-	// Free up the slot
-	s.db.Model(&models.AvailableSlot{}).Where(
-		"expert_id = ? AND start_time <= ? AND end_time >= ?",
-		booking.ExpertID, booking.StartTime, booking.EndTime,
-	).Update("is_booked", false)
+	if err := s.applyTransition(tx, booking, actor, role, to, reason, updates); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// TransitionBooking moves a booking to a new status if the lifecycle and
+// the actor's role on the booking allow it, then runs the registered hooks
+// in the same transaction.
+func (s *BookingService) TransitionBooking(bookingID uint, actor Actor, to string, reason string) (*models.Booking, error) {
+	if !IsValidBookingStatus(to) {
+		return nil, ErrUnknownBookingStatus
+	}
 
+	// Cancellations are subject to the expert's cancellation policy
+	if to == models.BookingStatusCancelled {
+		return s.CancelBooking(bookingID, actor, reason)
+	}
+
+	var booking *models.Booking
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		if booking, err = lockBooking(tx, bookingID); err != nil {
+			return err
+		}
+
+		if err := Authorize(actor, ActionUpdateStatus, booking); err != nil {
+			return err
+		}
+		role := bookingRole(booking, actor)
+
+		if err := CheckBookingTransition(booking.Status, to, role); err != nil {
+			return err
+		}
+
+		return s.applyTransition(tx, booking, actor, role, to, reason, map[string]interface{}{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, booking.ExpertID)
+
+	return booking, nil
+}
+
+// applyTransition writes a status change the caller has already checked,
+// along with any extra column updates, and runs the transition hooks.
+func (s *BookingService) applyTransition(tx *gorm.DB, booking *models.Booking, actor Actor, role, to, reason string, updates map[string]interface{}) error {
+	from := booking.Status
+
+	updates["status"] = to
+	if reason != "" {
+		updates["cancel_reason"] = reason
+	}
+	if err := tx.Model(booking).Updates(updates).Error; err != nil {
+		return err
+	}
+	booking.Status = to
+	if reason != "" {
+		booking.CancelReason = reason
+	}
+
+	transition := BookingTransition{
+		Booking: booking,
+		From:    from,
+		To:      to,
+		Actor:   actor,
+		Role:    role,
+		Reason:  reason,
+	}
+	for _, hook := range s.hooks {
+		if err := hook(tx, transition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (s *BookingService) UpdateBookingStatus(bookingID uint, status string) error {
-	return s.db.Model(&models.Booking{}).Where("id = ?", bookingID).Update("status", status).Error
+// lockBooking locks a booking row for the rest of the transaction and loads
+// the parties. Only a missing row is reported as ErrBookingNotFound.
+func lockBooking(tx *gorm.DB, bookingID uint) (*models.Booking, error) {
+	var booking models.Booking
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, bookingID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Preload("User").Preload("Expert").Preload("Expert.User").First(&booking, bookingID).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
 }
 
 func (s *BookingService) GetUpcomingBookings() ([]models.Booking, error) {
 	var bookings []models.Booking
-	err := s.db.Where("start_time > ? AND start_time < ? AND status IN (?)", 
-		time.Now(), time.Now().Add(time.Hour*2), []string{"pending", "confirmed"}).
+	err := s.db.Where("start_time > ? AND start_time < ? AND status IN (?)",
+		time.Now(), time.Now().Add(time.Hour*2), []string{models.BookingStatusPending, models.BookingStatusConfirmed}).
 		Preload("User").
 		Preload("Expert").
 		Preload("Expert.User").
 		Find(&bookings).Error
 	return bookings, err
-}
\ No newline at end of file
+}
+
+// releaseSlotHook frees the slot held by a booking that will no longer take
+// place.
+func releaseSlotHook(tx *gorm.DB, t BookingTransition) error {
+	if !slotReleasingStatuses[t.To] {
+		return nil
+	}
+
+	return releaseSlot(tx, t.Booking)
+}
+
+// releaseSlot frees the seat held by booking.
+func releaseSlot(tx *gorm.DB, booking *models.Booking) error {
+	if booking.SlotID != nil {
+		return releaseSeat(tx, *booking.SlotID)
+	}
+
+	// Bookings made before slot IDs were recorded
+	return tx.Model(&models.AvailableSlot{}).Where(
+		"expert_id = ? AND start_time <= ? AND end_time >= ?",
+		booking.ExpertID, booking.StartTime, booking.EndTime,
+	).Updates(seatReleased()).Error
+}
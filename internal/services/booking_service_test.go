@@ -0,0 +1,82 @@
+// internal/services/booking_service_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateBookingConcurrentSameSlot(t *testing.T) {
+	env := newTestEnv(t)
+	_, expert := env.createExpert(t)
+	slot := env.createSlot(t, expert.ID, futureHour(48), time.Hour)
+
+	const clients = 10
+	userIDs := make([]uint, clients)
+	for i := range userIDs {
+		userIDs[i] = env.createUser(t, "user").ID
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, clients)
+	start := make(chan struct{})
+	for i := range userIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = env.bookings.CreateBooking(userIDs[i], CreateBookingRequest{
+				ExpertID:  expert.ID,
+				StartTime: slot.StartTime,
+				EndTime:   slot.EndTime,
+			})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrSlotTaken), errors.Is(err, ErrExpertConflict), errors.Is(err, ErrSlotUnavailable):
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d bookings succeeded, want exactly 1", succeeded)
+	}
+
+	var bookings int64
+	env.db.Model(&models.Booking{}).Where("slot_id = ?", slot.ID).Count(&bookings)
+	if bookings != 1 {
+		t.Errorf("slot has %d bookings, want 1", bookings)
+	}
+
+	var stored models.AvailableSlot
+	env.db.First(&stored, slot.ID)
+	if !stored.IsBooked || stored.BookedCount != 1 {
+		t.Errorf("slot is_booked=%v booked_count=%d, want true and 1", stored.IsBooked, stored.BookedCount)
+	}
+}
+
+func TestLockBookingErrors(t *testing.T) {
+	env := newTestEnv(t)
+
+	if _, err := lockBooking(env.db, 999999); !errors.Is(err, ErrBookingNotFound) {
+		t.Fatalf("locking a missing booking: got %v, want ErrBookingNotFound", err)
+	}
+
+	// Database failures are passed through rather than reported as 404s
+	if err := env.db.Migrator().DropTable(&models.Booking{}); err != nil {
+		t.Fatalf("dropping bookings: %v", err)
+	}
+	if _, err := lockBooking(env.db, 1); err == nil || errors.Is(err, ErrBookingNotFound) {
+		t.Fatalf("locking with the table gone: got %v, want the database error", err)
+	}
+}
@@ -0,0 +1,145 @@
+// internal/services/cancellation_policy.go
+package services
+
+import (
+	"consultation-booking/internal/config"
+	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCancellationTooLate       = errors.New("too late to cancel this booking")
+	ErrCancellationLimitReached  = errors.New("monthly cancellation limit reached")
+	ErrInvalidCancellationPolicy = errors.New("late cancellation outcome must be block, no_show or fee")
+)
+
+// Codes returned with cancellation errors so API clients can tell them
+// apart without parsing messages
+const (
+	CodeCancellationTooLate      = "cancellation_too_late"
+	CodeCancellationLimitReached = "cancellation_limit_reached"
+)
+
+// CancellationError is a cancellation refused by the expert's policy.
+type CancellationError struct {
+	Code   string
+	Err    error
+	Detail string
+}
+
+func (e *CancellationError) Error() string {
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *CancellationError) Unwrap() error {
+	return e.Err
+}
+
+type CancellationPolicyRequest struct {
+	ClientCutoffMinutes            int    `json:"client_cutoff_minutes" binding:"min=0"`
+	ExpertCutoffMinutes            int    `json:"expert_cutoff_minutes" binding:"min=0"`
+	MaxClientCancellationsPerMonth int    `json:"max_client_cancellations_per_month" binding:"min=0"`
+	LateCancellationOutcome        string `json:"late_cancellation_outcome" binding:"required"`
+}
+
+// GetCancellationPolicy returns the policy that applies to the expert's
+// bookings: their own, or the platform default.
+func (s *ExpertService) GetCancellationPolicy(expertID uint) (*models.CancellationPolicy, error) {
+	return loadCancellationPolicy(s.db, expertID, s.policy.Cancellation)
+}
+
+// UpdateCancellationPolicy sets the expert's own cancellation policy.
+func (s *ExpertService) UpdateCancellationPolicy(expertID uint, req CancellationPolicyRequest) (*models.CancellationPolicy, error) {
+	switch req.LateCancellationOutcome {
+	case models.LateCancellationBlock, models.LateCancellationNoShow, models.LateCancellationFee:
+	default:
+		return nil, ErrInvalidCancellationPolicy
+	}
+
+	var policy models.CancellationPolicy
+	if err := s.db.Where("expert_id = ?", expertID).FirstOrInit(&policy).Error; err != nil {
+		return nil, err
+	}
+
+	policy.ExpertID = expertID
+	policy.ClientCutoffMinutes = req.ClientCutoffMinutes
+	policy.ExpertCutoffMinutes = req.ExpertCutoffMinutes
+	policy.MaxClientCancellationsPerMonth = req.MaxClientCancellationsPerMonth
+	policy.LateCancellationOutcome = req.LateCancellationOutcome
+
+	if err := s.db.Save(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ResetCancellationPolicy drops the expert's own policy so the platform
+// default applies again.
+func (s *ExpertService) ResetCancellationPolicy(expertID uint) error {
+	return s.db.Where("expert_id = ?", expertID).Delete(&models.CancellationPolicy{}).Error
+}
+
+func defaultCancellationPolicy(cfg config.CancellationConfig) *models.CancellationPolicy {
+	return &models.CancellationPolicy{
+		ClientCutoffMinutes:            int(cfg.ClientCutoff.Minutes()),
+		ExpertCutoffMinutes:            int(cfg.ExpertCutoff.Minutes()),
+		MaxClientCancellationsPerMonth: cfg.MaxClientCancellationsPerMonth,
+		LateCancellationOutcome:        cfg.LateOutcome,
+		IsDefault:                      true,
+	}
+}
+
+func loadCancellationPolicy(db *gorm.DB, expertID uint, defaults config.CancellationConfig) (*models.CancellationPolicy, error) {
+	var policy models.CancellationPolicy
+	err := db.Where("expert_id = ?", expertID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultCancellationPolicy(defaults), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// cancellationCutoff is how long before the start the role may still
+// cancel freely. Admins are not bound by it.
+func cancellationCutoff(policy *models.CancellationPolicy, role string) time.Duration {
+	switch role {
+	case ActorRoleClient:
+		return time.Duration(policy.ClientCutoffMinutes) * time.Minute
+	case ActorRoleExpert:
+		return time.Duration(policy.ExpertCutoffMinutes) * time.Minute
+	}
+	return 0
+}
+
+// checkCancellationLimit counts the client's cancellations in the current
+// calendar month of their time zone.
+func checkCancellationLimit(tx *gorm.DB, booking *models.Booking, policy *models.CancellationPolicy, now time.Time) error {
+	if policy.MaxClientCancellationsPerMonth <= 0 {
+		return nil
+	}
+
+	local := now.In(locationOrUTC(booking.User.Timezone))
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, local.Location())
+
+	var count int64
+	if err := tx.Model(&models.Booking{}).
+		Where("user_id = ? AND cancelled_by = ? AND cancelled_at >= ?", booking.UserID, ActorRoleClient, monthStart.UTC()).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count >= int64(policy.MaxClientCancellationsPerMonth) {
+		return &CancellationError{
+			Code:   CodeCancellationLimitReached,
+			Err:    ErrCancellationLimitReached,
+			Detail: fmt.Sprintf("at most %d cancellations per month are allowed", policy.MaxClientCancellationsPerMonth),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// internal/services/cancellation_policy_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"testing"
+)
+
+func TestLateClientCancellationWithNoShowPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirm    bool
+		wantStatus string
+	}{
+		{"confirmed booking is a no-show", true, models.BookingStatusNoShow},
+		{"pending booking stays cancelled", false, models.BookingStatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			expertUser, expert := env.createExpert(t)
+			client := env.createUser(t, "user")
+			if err := env.db.Create(&models.CancellationPolicy{
+				ExpertID:                expert.ID,
+				ClientCutoffMinutes:     24 * 60,
+				LateCancellationOutcome: models.LateCancellationNoShow,
+			}).Error; err != nil {
+				t.Fatalf("creating policy: %v", err)
+			}
+
+			booking := env.book(t, client.ID, expert.ID, futureHour(3))
+			if tt.confirm {
+				if _, err := env.bookings.TransitionBooking(booking.ID, actorFor(expertUser), models.BookingStatusConfirmed, ""); err != nil {
+					t.Fatalf("confirming: %v", err)
+				}
+			}
+
+			cancelled, err := env.bookings.CancelBooking(booking.ID, actorFor(client), "")
+			if err != nil {
+				t.Fatalf("CancelBooking: %v", err)
+			}
+			if cancelled.Status != tt.wantStatus || !cancelled.LateCancellation {
+				t.Fatalf("status %q late=%v, want %q and late", cancelled.Status, cancelled.LateCancellation, tt.wantStatus)
+			}
+
+			// Both parties hear of a late cancellation
+			for _, userID := range []uint{client.ID, expertUser.ID} {
+				var count int64
+				env.db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", userID, "cancellation").Count(&count)
+				if count != 1 {
+					t.Errorf("user %d got %d cancellation notices, want 1", userID, count)
+				}
+			}
+		})
+	}
+}
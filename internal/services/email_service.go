@@ -5,15 +5,18 @@ import (
 	"consultation-booking/internal/config"
 	"fmt"
 	"net/smtp"
+	"time"
 )
 
 type EmailService struct {
 	smtpConfig config.SMTPConfig
+	appURL     string
 }
 
-func NewEmailService(smtpConfig config.SMTPConfig) *EmailService {
+func NewEmailService(smtpConfig config.SMTPConfig, appURL string) *EmailService {
 	return &EmailService{
 		smtpConfig: smtpConfig,
+		appURL:     appURL,
 	}
 }
 
@@ -53,7 +56,8 @@ func (s *EmailService) SendWelcomeEmail(to, name string) error {
 	return s.SendEmail(to, subject, body)
 }
 
-func (s *EmailService) SendBookingConfirmation(to, expertName, startTime string) error {
+// SendBookingConfirmation renders startTime in the recipient's time zone.
+func (s *EmailService) SendBookingConfirmation(to, expertName string, startTime time.Time, timezone string) error {
 	subject := "Booking Confirmation"
 	body := fmt.Sprintf(`
 		Your consultation booking has been confirmed!
@@ -65,12 +69,13 @@ func (s *EmailService) SendBookingConfirmation(to, expertName, startTime string)
 
 		Best regards,
 		Consultation Booking Team
-	`, expertName, startTime)
+	`, expertName, FormatInZone(startTime, timezone))
 
 	return s.SendEmail(to, subject, body)
 }
 
-func (s *EmailService) SendReminder(to, expertName, startTime string) error {
+// SendReminder renders startTime in the recipient's time zone.
+func (s *EmailService) SendReminder(to, expertName string, startTime time.Time, timezone string) error {
 	subject := "Consultation Reminder"
 	body := fmt.Sprintf(`
 		Reminder: Your consultation is starting soon!
@@ -82,7 +87,65 @@ func (s *EmailService) SendReminder(to, expertName, startTime string) error {
 
 		Best regards,
 		Consultation Booking Team
-	`, expertName, startTime)
+	`, expertName, FormatInZone(startTime, timezone))
+
+	return s.SendEmail(to, subject, body)
+}
+
+// SendWaitlistOffer renders startTime and expiresAt in the recipient's
+// time zone.
+func (s *EmailService) SendWaitlistOffer(to, expertName string, startTime, expiresAt time.Time, timezone string) error {
+	subject := "A consultation slot opened up"
+	body := fmt.Sprintf(`
+		Good news! A slot you were waiting for is available.
+
+		Expert: %s
+		Time: %s
+
+		It is held for you until %s. Claim it from your waitlist offers
+		before then, or it will be offered to the next person in line:
+
+		%s/waitlist/offers
+
+		Best regards,
+		Consultation Booking Team
+	`, expertName, FormatInZone(startTime, timezone), FormatInZone(expiresAt, timezone), s.appURL)
+
+	return s.SendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendPasswordReset(to, name, token string) error {
+	subject := "Reset your password"
+	body := fmt.Sprintf(`
+		Dear %s,
+
+		We received a request to reset your password. Use the link below
+		within 30 minutes to choose a new one:
+
+		%s/reset-password?token=%s
+
+		If you didn't ask for this, you can ignore this email.
+
+		Best regards,
+		Consultation Booking Team
+	`, name, s.appURL, token)
+
+	return s.SendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendVerificationEmail(to, name, token string) error {
+	subject := "Verify your email address"
+	body := fmt.Sprintf(`
+		Dear %s,
+
+		Thanks for signing up! Please confirm your email address by opening
+		the link below within 24 hours:
+
+		%s/verify-email?token=%s
+
+		Best regards,
+		Consultation Booking Team
+	`, name, s.appURL, token)
 
 	return s.SendEmail(to, subject, body)
 }
@@ -0,0 +1,100 @@
+// internal/services/email_verification.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	emailVerificationPurpose = "email_verification"
+	emailVerificationTTL     = 24 * time.Hour
+
+	// Minimum time between verification emails for one user
+	emailVerificationResendInterval = time.Minute
+)
+
+var (
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+	ErrResendTooSoon        = errors.New("please wait before requesting another verification email")
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail marks the account's email as verified and sends the welcome
+// email. The token only verifies the address it was sent to.
+func (s *UserService) VerifyEmail(req VerifyEmailRequest) error {
+	userID, email, err := consumeOneTimeToken(context.Background(), s.redis, emailVerificationPurpose, req.Token)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil || user.Email != email {
+		return ErrInvalidOneTimeToken
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.User{}).Where("id = ? AND email = ?", user.ID, email).Updates(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidOneTimeToken
+	}
+
+	go func() {
+		if err := s.emailService.SendWelcomeEmail(user.Email, user.Name); err != nil {
+			log.Printf("Failed to send welcome email: %v", err)
+		}
+	}()
+	return nil
+}
+
+// ResendVerification issues a new verification token, invalidating the
+// previous one.
+func (s *UserService) ResendVerification(userID uint) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	throttleKey := fmt.Sprintf("email_verification_resend:%d", userID)
+	ok, err := s.redis.SetNX(context.Background(), throttleKey, 1, emailVerificationResendInterval).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrResendTooSoon
+	}
+
+	go s.sendVerificationEmail(user)
+	return nil
+}
+
+func (s *UserService) sendVerificationEmail(user models.User) {
+	token, err := issueOneTimeToken(context.Background(), s.redis, emailVerificationPurpose, user.ID, user.Email, emailVerificationTTL)
+	if err != nil {
+		log.Printf("Failed to issue email verification token: %v", err)
+		return
+	}
+
+	if err := s.emailService.SendVerificationEmail(user.Email, user.Name, token); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+}
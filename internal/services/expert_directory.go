@@ -0,0 +1,213 @@
+// internal/services/expert_directory.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidDirectorySort = errors.New("sort must be rating, experience or price")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+)
+
+// Directory orders. Ties are broken by expert ID so paging is stable.
+const (
+	DirectorySortRating     = "rating"     // best rated first
+	DirectorySortExperience = "experience" // most experienced first
+	DirectorySortPrice      = "price"      // cheapest session type first, unpriced last
+)
+
+const (
+	defaultDirectoryLimit = 20
+	maxDirectoryLimit     = 100
+	unpricedSortKey       = math.MaxInt64
+)
+
+// Lowest price of the expert's active session types
+const fromPriceSQL = "(SELECT MIN(session_types.price_cents) FROM session_types" +
+	" WHERE session_types.expert_id = experts.id AND session_types.is_active = true AND session_types.deleted_at IS NULL)"
+
+// ExpertDirectoryRequest filters and pages the expert directory. Cursor is
+// the next_cursor of the previous page.
+type ExpertDirectoryRequest struct {
+	Speciality    string  `form:"speciality"`
+	MinExperience int     `form:"min_experience" binding:"omitempty,min=0"`
+	MinRating     float64 `form:"min_rating" binding:"omitempty,min=0"`
+	Gender        string  `form:"gender"`
+	Format        string  `form:"format"` // online, offline
+	Query         string  `form:"q"`      // matched against name and description
+	Sort          string  `form:"sort"`   // rating (default), experience, price
+	Cursor        string  `form:"cursor"`
+	Limit         int     `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ExpertPage is one page of the directory. Total counts every expert
+// matching the filters; NextCursor is empty on the last page.
+type ExpertPage struct {
+	Experts    []models.Expert `json:"experts"`
+	Total      int64           `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// directoryCursor is the sort value and ID of the last expert on a page.
+type directoryCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// GetExperts returns a page of available experts matching the filters.
+// Pages are keyed on the sort value and ID of the last expert rather than
+// an offset, so they stay consistent while experts are added.
+func (s *ExpertService) GetExperts(req ExpertDirectoryRequest) (*ExpertPage, error) {
+	if req.Sort == "" {
+		req.Sort = DirectorySortRating
+	}
+	if req.Sort != DirectorySortRating && req.Sort != DirectorySortExperience && req.Sort != DirectorySortPrice {
+		return nil, ErrInvalidDirectorySort
+	}
+	if req.Format != "" && req.Format != "online" && req.Format != "offline" {
+		return nil, ErrInvalidFormat
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultDirectoryLimit
+	}
+	if limit > maxDirectoryLimit {
+		limit = maxDirectoryLimit
+	}
+
+	var total int64
+	if err := s.directoryQuery(req).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	sortExpr, desc := "experts.rating", true
+	switch req.Sort {
+	case DirectorySortExperience:
+		sortExpr = "experts.experience"
+	case DirectorySortPrice:
+		// Unpriced experts sort after every price
+		sortExpr, desc = fmt.Sprintf("COALESCE(%s, %d)", fromPriceSQL, unpricedSortKey), false
+	}
+
+	query := s.directoryQuery(req).Select("experts.*, " + fromPriceSQL + " AS from_price_cents")
+	if req.Cursor != "" {
+		cursor, value, err := decodeDirectoryCursor(req.Cursor, req.Sort)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		query = query.Where("("+sortExpr+" "+op+" ?) OR ("+sortExpr+" = ? AND experts.id > ?)", value, value, cursor.ID)
+	}
+
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+	query = query.Order(sortExpr + direction + ", experts.id ASC")
+
+	var experts []models.Expert
+	if err := query.Preload("User").Preload("Languages").Limit(limit + 1).Find(&experts).Error; err != nil {
+		return nil, err
+	}
+
+	page := &ExpertPage{Experts: experts, Total: total}
+	if len(experts) > limit {
+		page.Experts = experts[:limit]
+		page.NextCursor = encodeDirectoryCursor(req.Sort, &experts[limit-1])
+	}
+	return page, nil
+}
+
+// directoryQuery selects the available experts matching the filters.
+func (s *ExpertService) directoryQuery(req ExpertDirectoryRequest) *gorm.DB {
+	query := s.db.Model(&models.Expert{}).
+		Joins("JOIN users ON users.id = experts.user_id AND users.deleted_at IS NULL").
+		Where("experts.is_available = ?", true)
+
+	if speciality := strings.TrimSpace(req.Speciality); speciality != "" {
+		query = query.Where("lower(experts.speciality) = ?", strings.ToLower(speciality))
+	}
+	if req.MinExperience > 0 {
+		query = query.Where("experts.experience >= ?", req.MinExperience)
+	}
+	if req.MinRating > 0 {
+		query = query.Where("experts.rating >= ?", req.MinRating)
+	}
+	if gender := strings.TrimSpace(req.Gender); gender != "" {
+		query = query.Where("lower(users.gender) = ?", strings.ToLower(gender))
+	}
+	switch req.Format {
+	case "online":
+		query = query.Where("experts.offers_online = ?", true)
+	case "offline":
+		query = query.Where("experts.offers_offline = ?", true)
+	}
+	if text := strings.TrimSpace(req.Query); text != "" {
+		pattern := "%" + likeEscaper.Replace(text) + "%"
+		query = query.Where(`(lower(users.name) LIKE lower(?) ESCAPE '\' OR lower(users.description) LIKE lower(?) ESCAPE '\')`, pattern, pattern)
+	}
+	return query
+}
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func encodeDirectoryCursor(sort string, last *models.Expert) string {
+	cursor := directoryCursor{Sort: sort, ID: last.ID}
+	switch sort {
+	case DirectorySortRating:
+		cursor.Value = strconv.FormatFloat(last.Rating, 'g', -1, 64)
+	case DirectorySortExperience:
+		cursor.Value = strconv.Itoa(last.Experience)
+	case DirectorySortPrice:
+		price := int64(unpricedSortKey)
+		if last.FromPriceCents != nil {
+			price = *last.FromPriceCents
+		}
+		cursor.Value = strconv.FormatInt(price, 10)
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeDirectoryCursor parses a cursor made for the same sort and returns
+// its sort value in the type of the sort column.
+func decodeDirectoryCursor(encoded, sort string) (*directoryCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	var cursor directoryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch sort {
+	case DirectorySortRating:
+		value, err = strconv.ParseFloat(cursor.Value, 64)
+	case DirectorySortExperience:
+		value, err = strconv.Atoi(cursor.Value)
+	case DirectorySortPrice:
+		value, err = strconv.ParseInt(cursor.Value, 10, 64)
+	}
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	return &cursor, value, nil
+}
@@ -0,0 +1,141 @@
+// internal/services/expert_directory_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type directoryFixture struct {
+	name        string
+	description string
+	speciality  string
+	rating      float64
+	experience  int
+	prices      []int64 // active session types
+	inactive    bool    // an inactive session type that doesn't count
+}
+
+// newDirectory creates the fixtures' experts in order and returns their IDs
+// by name.
+func newDirectory(t *testing.T, env *testEnv, fixtures []directoryFixture) map[string]uint {
+	t.Helper()
+
+	ids := make(map[string]uint)
+	for _, f := range fixtures {
+		user, expert := env.createExpert(t)
+		env.db.Model(&user).Updates(map[string]interface{}{"name": f.name, "description": f.description})
+		speciality := f.speciality
+		if speciality == "" {
+			speciality = "Law"
+		}
+		env.db.Model(&expert).Updates(map[string]interface{}{
+			"speciality": speciality,
+			"rating":     f.rating,
+			"experience": f.experience,
+		})
+
+		for _, price := range f.prices {
+			env.db.Create(&models.SessionType{ExpertID: expert.ID, Name: "Session", DurationMinutes: 60, PriceCents: price, IsActive: true})
+		}
+		if f.inactive {
+			sessionType := models.SessionType{ExpertID: expert.ID, Name: "Retired", DurationMinutes: 60, PriceCents: 100}
+			env.db.Create(&sessionType)
+			env.db.Model(&sessionType).Update("is_active", false)
+		}
+		ids[f.name] = expert.ID
+	}
+	return ids
+}
+
+// pageThrough follows next cursors to the end, checking every page reports
+// the same total, and returns the IDs in order.
+func pageThrough(t *testing.T, env *testEnv, req ExpertDirectoryRequest, wantTotal int64) []uint {
+	t.Helper()
+
+	var ids []uint
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("paging doesn't end")
+		}
+		page, err := env.experts.GetExperts(req)
+		if err != nil {
+			t.Fatalf("GetExperts %+v: %v", req, err)
+		}
+		if page.Total != wantTotal {
+			t.Fatalf("GetExperts %+v: total %d, want %d", req, page.Total, wantTotal)
+		}
+		for _, expert := range page.Experts {
+			ids = append(ids, expert.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+func TestExpertDirectoryPaging(t *testing.T) {
+	env := newTestEnv(t)
+	ids := newDirectory(t, env, []directoryFixture{
+		{name: "Alice Smith", rating: 4.5, experience: 5, prices: []int64{5000, 9000}},
+		{name: "Bob", rating: 4.5, experience: 3, prices: []int64{3000}},
+		{name: "Carol", rating: 4.0, experience: 5, prices: []int64{3000}},
+		{name: "Dan", rating: 3.0, experience: 10},
+		{name: "Eve", rating: 5.0, experience: 1},
+		{name: "Frank", description: "Tax and family law", speciality: "Tax", rating: 4.0, experience: 2, prices: []int64{8000}},
+		{name: "Grace", description: "100 satisfied clients", rating: 2.0, experience: 7, inactive: true},
+	})
+	order := func(names ...string) []uint {
+		out := make([]uint, len(names))
+		for i, name := range names {
+			out[i] = ids[name]
+		}
+		return out
+	}
+
+	// Ties are broken by ID; unpriced experts, Grace's only session type
+	// being inactive, come last by price
+	sorts := map[string][]uint{
+		DirectorySortRating:     order("Eve", "Alice Smith", "Bob", "Carol", "Frank", "Dan", "Grace"),
+		DirectorySortExperience: order("Dan", "Grace", "Alice Smith", "Carol", "Bob", "Frank", "Eve"),
+		DirectorySortPrice:      order("Bob", "Carol", "Alice Smith", "Frank", "Dan", "Eve", "Grace"),
+	}
+	for sort, want := range sorts {
+		// Every page size puts a page break between some tied experts
+		for limit := 1; limit <= 3; limit++ {
+			got := pageThrough(t, env, ExpertDirectoryRequest{Sort: sort, Limit: limit}, 7)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("sort %s, %d per page: got %v, want %v", sort, limit, got, want)
+			}
+		}
+	}
+
+	filtered := []struct {
+		name  string
+		req   ExpertDirectoryRequest
+		want  []uint
+		total int64
+	}{
+		{"min rating by price", ExpertDirectoryRequest{MinRating: 4, Sort: DirectorySortPrice, Limit: 2},
+			order("Bob", "Carol", "Alice Smith", "Frank", "Eve"), 5},
+		{"speciality", ExpertDirectoryRequest{Speciality: "tax", Limit: 2}, order("Frank"), 1},
+		{"experience", ExpertDirectoryRequest{MinExperience: 5, Sort: DirectorySortExperience, Limit: 2},
+			order("Dan", "Grace", "Alice Smith", "Carol"), 4},
+		{"name in any case", ExpertDirectoryRequest{Query: "ALICE"}, order("Alice Smith"), 1},
+		{"description", ExpertDirectoryRequest{Query: "Family"}, order("Frank"), 1},
+		{"wildcards are literal", ExpertDirectoryRequest{Query: "100%"}, nil, 0},
+	}
+	for _, tt := range filtered {
+		got := pageThrough(t, env, tt.req, tt.total)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := env.experts.GetExperts(ExpertDirectoryRequest{Sort: DirectorySortPrice, Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("garbage cursor: got %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,199 @@
+// internal/services/expert_search.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSearchRange = errors.New("search range must end after it starts and span at most 31 days")
+	ErrInvalidFormat      = errors.New("format must be online or offline")
+	ErrInvalidSearchSort  = errors.New("sort must be earliest or rating")
+)
+
+// Search result orders
+const (
+	SearchSortEarliest = "earliest" // soonest free slot first
+	SearchSortRating   = "rating"   // best rated first
+)
+
+const (
+	maxSearchRange       = 31 * 24 * time.Hour
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 50
+	slotsPerSearchResult = 10
+)
+
+// SlotSearchRequest finds experts with free slots between From and To.
+// Prices are in cents and match any of the expert's active session types.
+type SlotSearchRequest struct {
+	From          time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" binding:"required"`
+	To            time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" binding:"required"`
+	Speciality    string    `form:"speciality"`
+	Format        string    `form:"format"`   // online, offline
+	Language      string    `form:"language"` // ISO 639-1 code
+	MinPriceCents *int64    `form:"min_price_cents" binding:"omitempty,min=0"`
+	MaxPriceCents *int64    `form:"max_price_cents" binding:"omitempty,min=0"`
+	Currency      string    `form:"currency"`
+	Sort          string    `form:"sort"` // earliest (default), rating
+	Limit         int       `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ExpertAvailability is an expert matching a search with their first free
+// slots in the searched range.
+type ExpertAvailability struct {
+	Expert         models.Expert          `json:"expert"`
+	FirstAvailable time.Time              `json:"first_available"`
+	Slots          []models.AvailableSlot `json:"slots"`
+}
+
+// SearchAvailability finds experts matching the filters who have a free
+// slot that lies within the searched range. Experts are picked by a single
+// grouped query over the open slots, then their slots are narrowed to what
+// the expert's scheduling rules and current holds let a client book, so a
+// page can come back shorter than the limit. Slots from recurring rules
+// are found once materialized, which the worker keeps up to date.
+func (s *ExpertService) SearchAvailability(req SlotSearchRequest) ([]ExpertAvailability, error) {
+	from, to := req.From.UTC(), req.To.UTC()
+	if !to.After(from) || to.Sub(from) > maxSearchRange {
+		return nil, ErrInvalidSearchRange
+	}
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+
+	if req.Format != "" && req.Format != "online" && req.Format != "offline" {
+		return nil, ErrInvalidFormat
+	}
+	if req.Sort == "" {
+		req.Sort = SearchSortEarliest
+	}
+	if req.Sort != SearchSortEarliest && req.Sort != SearchSortRating {
+		return nil, ErrInvalidSearchSort
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	query := s.db.Table("available_slots").
+		Select("available_slots.expert_id, MIN(available_slots.start_time) AS first_start").
+		Joins("JOIN experts ON experts.id = available_slots.expert_id AND experts.deleted_at IS NULL").
+		Where("available_slots.deleted_at IS NULL AND available_slots.is_booked = ? AND available_slots.start_time >= ? AND available_slots.end_time <= ?",
+			false, from, to).
+		Where("experts.is_available = ?", true)
+
+	if speciality := strings.TrimSpace(req.Speciality); speciality != "" {
+		query = query.Where("lower(experts.speciality) = ?", strings.ToLower(speciality))
+	}
+	switch req.Format {
+	case "online":
+		query = query.Where("experts.offers_online = ?", true)
+	case "offline":
+		query = query.Where("experts.offers_offline = ?", true)
+	}
+	if language := strings.ToLower(strings.TrimSpace(req.Language)); language != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM expert_languages WHERE expert_languages.expert_id = experts.id AND expert_languages.language = ?)", language)
+	}
+	if req.MinPriceCents != nil || req.MaxPriceCents != nil || req.Currency != "" {
+		priced := s.db.Table("session_types").Select("1").
+			Where("session_types.expert_id = experts.id AND session_types.is_active = ? AND session_types.deleted_at IS NULL", true)
+		if req.MinPriceCents != nil {
+			priced = priced.Where("session_types.price_cents >= ?", *req.MinPriceCents)
+		}
+		if req.MaxPriceCents != nil {
+			priced = priced.Where("session_types.price_cents <= ?", *req.MaxPriceCents)
+		}
+		if req.Currency != "" {
+			priced = priced.Where("session_types.currency = ?", strings.ToUpper(req.Currency))
+		}
+		query = query.Where("EXISTS (?)", priced)
+	}
+
+	query = query.Group("available_slots.expert_id, experts.rating")
+	if req.Sort == SearchSortRating {
+		query = query.Order("experts.rating DESC, first_start")
+	} else {
+		query = query.Order("first_start, experts.rating DESC")
+	}
+
+	// Scheduling rules drop some experts afterwards, so look at a few more
+	var candidates []struct {
+		ExpertID uint
+	}
+	if err := query.Limit(limit * 2).Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return []ExpertAvailability{}, nil
+	}
+
+	ids := make([]uint, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ExpertID
+	}
+
+	var experts []models.Expert
+	if err := s.db.Preload("User").Preload("Languages").Preload("SessionTypes", "is_active = ?", true).
+		Where("id IN ?", ids).Find(&experts).Error; err != nil {
+		return nil, err
+	}
+	expertsByID := make(map[uint]models.Expert, len(experts))
+	for _, e := range experts {
+		expertsByID[e.ID] = e
+	}
+
+	var slots []models.AvailableSlot
+	if err := s.db.Where("expert_id IN ? AND is_booked = ? AND start_time >= ? AND end_time <= ?", ids, false, from, to).
+		Order("start_time").Find(&slots).Error; err != nil {
+		return nil, err
+	}
+	slotsByExpert := make(map[uint][]models.AvailableSlot)
+	for _, slot := range slots {
+		slotsByExpert[slot.ExpertID] = append(slotsByExpert[slot.ExpertID], slot)
+	}
+
+	results := []ExpertAvailability{}
+	for _, id := range ids {
+		expert, ok := expertsByID[id]
+		if !ok {
+			continue
+		}
+
+		bookable, err := s.bookableSlots(id, slotsByExpert[id])
+		if err != nil {
+			return nil, err
+		}
+		if len(bookable) == 0 {
+			continue
+		}
+		if len(bookable) > slotsPerSearchResult {
+			bookable = bookable[:slotsPerSearchResult]
+		}
+
+		results = append(results, ExpertAvailability{
+			Expert:         expert,
+			FirstAvailable: bookable[0].StartTime,
+			Slots:          bookable,
+		})
+	}
+
+	// Scheduling rules can push an expert's first bookable slot past
+	// other candidates'
+	if req.Sort == SearchSortEarliest {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].FirstAvailable.Before(results[j].FirstAvailable)
+		})
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
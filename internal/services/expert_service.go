@@ -2,61 +2,190 @@
 package services
 
 import (
+	"consultation-booking/internal/config"
 	"consultation-booking/internal/models"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrNoExpertProfile = errors.New("user has no expert profile")
+	ErrInvalidLanguage = errors.New("languages must be ISO 639-1 codes")
+)
+
+// How long the user -> expert ID mapping is cached
+const expertByUserTTL = time.Hour
+
 type ExpertService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db        *gorm.DB
+	redis     *redis.Client
+	policy    config.BookingConfig
+	slotLoads slotLoader
+}
+
+// ExpertProfileRequest changes what clients can find the expert by. Fields
+// left out keep their current value.
+type ExpertProfileRequest struct {
+	Timezone      string   `json:"timezone"`
+	Languages     []string `json:"languages"` // replaces the current languages
+	OffersOnline  *bool    `json:"offers_online"`
+	OffersOffline *bool    `json:"offers_offline"`
 }
 
 type CreateSlotRequest struct {
 	StartTime time.Time `json:"start_time" binding:"required"`
 	EndTime   time.Time `json:"end_time" binding:"required"`
+	Capacity  int       `json:"capacity" binding:"min=0"` // seats, 1 when omitted
 }
 
-func NewExpertService(db *gorm.DB, redis *redis.Client) *ExpertService {
+func NewExpertService(db *gorm.DB, redis *redis.Client, policy config.BookingConfig) *ExpertService {
 	return &ExpertService{
-		db:    db,
-		redis: redis,
+		db:     db,
+		redis:  redis,
+		policy: policy,
 	}
 }
 
 func (s *ExpertService) CreateExpert(userID uint, speciality string, experience int) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	// Experts start out in the zone of their user profile
 	expert := models.Expert{
 		UserID:     userID,
 		Speciality: speciality,
 		Experience: experience,
+		Timezone:   user.Timezone,
 	}
 
 	if err := s.db.Create(&expert).Error; err != nil {
 		return err
 	}
+	s.redis.Del(context.Background(), expertByUserKey(userID))
 
 	// Update user role
 	return s.db.Model(&models.User{}).Where("id = ?", userID).Update("role", "expert").Error
 }
 
-func (s *ExpertService) GetExperts() ([]models.Expert, error) {
-	var experts []models.Expert
-	err := s.db.Preload("User").Where("is_available = ?", true).Find(&experts).Error
-	return experts, err
+// GetExpertByID returns an expert with the session types they offer and the
+// cancellation policy and scheduling settings that apply to their bookings.
+func (s *ExpertService) GetExpertByID(expertID uint) (*models.Expert, error) {
+	var expert models.Expert
+	if err := s.db.Preload("User").Preload("Languages").Preload("CancellationPolicy").Preload("Settings").
+		Preload("SessionTypes", "is_active = ?", true).
+		First(&expert, expertID).Error; err != nil {
+		return &expert, err
+	}
+
+	if expert.CancellationPolicy == nil {
+		expert.CancellationPolicy = defaultCancellationPolicy(s.policy.Cancellation)
+	}
+	if expert.Settings == nil {
+		expert.Settings = defaultExpertSettings(s.policy.Scheduling)
+	}
+	return &expert, nil
 }
 
-func (s *ExpertService) GetExpertByID(expertID uint) (*models.Expert, error) {
+// GetExpertByUserID returns the expert profile owned by a user account.
+func (s *ExpertService) GetExpertByUserID(userID uint) (*models.Expert, error) {
 	var expert models.Expert
-	err := s.db.Preload("User").First(&expert, expertID).Error
+	err := s.db.Where("user_id = ?", userID).First(&expert).Error
 	return &expert, err
 }
 
+// ExpertIDForUser resolves the expert profile owned by a user account,
+// cached in Redis since it's needed on every expert request.
+func (s *ExpertService) ExpertIDForUser(userID uint) (uint, error) {
+	ctx := context.Background()
+	cacheKey := expertByUserKey(userID)
+	if cached, err := s.redis.Get(ctx, cacheKey).Uint64(); err == nil {
+		return uint(cached), nil
+	}
+
+	var expert models.Expert
+	err := s.db.Select("id").Where("user_id = ?", userID).First(&expert).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrNoExpertProfile
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.redis.Set(ctx, cacheKey, expert.ID, expertByUserTTL)
+	return expert.ID, nil
+}
+
+// UpdateProfile applies an ExpertProfileRequest. Nothing is changed unless
+// the whole request is valid, and a new time zone regenerates the expert's
+// recurring slots.
+func (s *ExpertService) UpdateProfile(expertID uint, req ExpertProfileRequest) error {
+	var languages []models.ExpertLanguage
+	if req.Languages != nil {
+		seen := make(map[string]bool)
+		for _, code := range req.Languages {
+			code = strings.ToLower(strings.TrimSpace(code))
+			if len(code) != 2 || strings.Trim(code, "abcdefghijklmnopqrstuvwxyz") != "" {
+				return ErrInvalidLanguage
+			}
+			if !seen[code] {
+				seen[code] = true
+				languages = append(languages, models.ExpertLanguage{ExpertID: expertID, Language: code})
+			}
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.Timezone != "" {
+		if _, err := LoadTimezone(req.Timezone); err != nil {
+			return err
+		}
+		updates["timezone"] = req.Timezone
+	}
+	if req.OffersOnline != nil {
+		updates["offers_online"] = *req.OffersOnline
+	}
+	if req.OffersOffline != nil {
+		updates["offers_offline"] = *req.OffersOffline
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Expert{}).Where("id = ?", expertID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if req.Languages == nil {
+			return nil
+		}
+		if err := tx.Where("expert_id = ?", expertID).Delete(&models.ExpertLanguage{}).Error; err != nil {
+			return err
+		}
+		if len(languages) == 0 {
+			return nil
+		}
+		return tx.Create(&languages).Error
+	})
+	if err != nil || req.Timezone == "" {
+		return err
+	}
+
+	if err := materializeAvailability(s.db, expertID, time.Now()); err != nil {
+		return err
+	}
+	invalidateSlotCache(s.redis, expertID)
+	return nil
+}
+
 func (s *ExpertService) CreateAvailableSlot(expertID uint, req CreateSlotRequest) error {
 	// Check if expert exists
 	var expert models.Expert
@@ -64,60 +193,97 @@ func (s *ExpertService) CreateAvailableSlot(expertID uint, req CreateSlotRequest
 		return err
 	}
 
-	// Check for conflicts
-	var conflictCount int64
-	s.db.Model(&models.AvailableSlot{}).Where(
-		"expert_id = ? AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?))",
-		expertID, req.StartTime, req.StartTime, req.EndTime, req.EndTime,
-	).Count(&conflictCount)
+	req.StartTime = req.StartTime.UTC()
+	req.EndTime = req.EndTime.UTC()
+	if err := validateSlotTimes(req.StartTime, req.EndTime, time.Now()); err != nil {
+		return err
+	}
 
-	if conflictCount > 0 {
-		return errors.New("time slot conflicts with existing slot")
+	// Check for conflicts
+	overlaps, err := slotOverlaps(s.db, expertID, 0, req.StartTime, req.EndTime)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return ErrSlotOverlap
 	}
 
 	slot := models.AvailableSlot{
 		ExpertID:  expertID,
 		StartTime: req.StartTime,
 		EndTime:   req.EndTime,
+		Capacity:  seatCount(req.Capacity),
 	}
 
 	if err := s.db.Create(&slot).Error; err != nil {
 		return err
 	}
 
-	// Update cache
-	s.updateAvailableSlotsCache(expertID)
+	invalidateSlotCache(s.redis, expertID)
 	return nil
 }
 
 func (s *ExpertService) GetAvailableSlots(expertID uint) ([]models.AvailableSlot, error) {
-	// Try to get from cache first
-	cacheKey := fmt.Sprintf("available_slots:%d", expertID)
-	cached, err := s.redis.Get(context.Background(), cacheKey).Result()
-	if err == nil {
-		var slots []models.AvailableSlot
-		if err := json.Unmarshal([]byte(cached), &slots); err == nil {
-			return slots, nil
+	ctx := context.Background()
+	version, err := s.redis.Get(ctx, slotCacheVersionKey(expertID)).Int64()
+	if err != nil && err != redis.Nil {
+		// Without the version the cache can't be trusted
+		slots, err := s.loadAvailableSlots(expertID)
+		if err != nil {
+			return nil, err
 		}
+		return s.bookableSlots(expertID, slots)
 	}
 
-	// Get from database
+	cacheKey := slotCacheKey(expertID, version)
+	slots, err := s.slotLoads.do(cacheKey, func() ([]models.AvailableSlot, error) {
+		// Try to get from cache first
+		if cached, err := s.redis.Get(ctx, cacheKey).Result(); err == nil {
+			var slots []models.AvailableSlot
+			if err := json.Unmarshal([]byte(cached), &slots); err == nil {
+				return slots, nil
+			}
+		}
+
+		slots, err := s.loadAvailableSlots(expertID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Cache the result
+		if data, err := json.Marshal(slots); err == nil {
+			s.redis.Set(ctx, cacheKey, string(data), slotCacheTTL)
+		}
+		return slots, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.bookableSlots(expertID, slots)
+}
+
+// loadAvailableSlots reads the expert's free slots from the database.
+// Slots from recurring rules are generated when the rules change and rolled
+// forward by the worker, not here.
+func (s *ExpertService) loadAvailableSlots(expertID uint) ([]models.AvailableSlot, error) {
 	var slots []models.AvailableSlot
-	err = s.db.Where("expert_id = ? AND is_booked = ? AND start_time > ?",
+	err := s.db.Where("expert_id = ? AND is_booked = ? AND start_time > ?",
 		expertID, false, time.Now()).
 		Order("start_time").
 		Find(&slots).Error
+	return slots, err
+}
 
+// bookableSlots narrows a cached or fresh listing to what a client can book
+// right now. Scheduling rules and holds depend on the time, so they are
+// applied after the cache.
+func (s *ExpertService) bookableSlots(expertID uint, slots []models.AvailableSlot) ([]models.AvailableSlot, error) {
+	slots, err := s.applySchedulingRules(expertID, slots)
 	if err != nil {
 		return nil, err
 	}
-
-	// Cache the result
-	if data, err := json.Marshal(slots); err == nil {
-		s.redis.Set(context.Background(), cacheKey, string(data), time.Hour)
-	}
-
-	return slots, nil
+	return applySlotHolds(context.Background(), s.redis, slots), nil
 }
 
 func (s *ExpertService) GetExpertBookings(expertID uint) ([]models.Booking, error) {
@@ -129,19 +295,6 @@ func (s *ExpertService) GetExpertBookings(expertID uint) ([]models.Booking, erro
 	return bookings, err
 }
 
-func (s *ExpertService) UpdateBookingStatus(bookingID uint, status string) error {
-	return s.db.Model(&models.Booking{}).Where("id = ?", bookingID).Update("status", status).Error
-}
-
-func (s *ExpertService) updateAvailableSlotsCache(expertID uint) {
-	var slots []models.AvailableSlot
-	s.db.Where("expert_id = ? AND is_booked = ? AND start_time > ?",
-		expertID, false, time.Now()).
-		Order("start_time").
-		Find(&slots)
-
-	cacheKey := fmt.Sprintf("available_slots:%d", expertID)
-	if data, err := json.Marshal(slots); err == nil {
-		s.redis.Set(context.Background(), cacheKey, string(data), time.Hour)
-	}
+func expertByUserKey(userID uint) string {
+	return fmt.Sprintf("expert_by_user:%d", userID)
 }
@@ -0,0 +1,61 @@
+// internal/services/expert_service_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"testing"
+)
+
+func TestExpertIDForUser(t *testing.T) {
+	env := newTestEnv(t)
+	expertUser, expert := env.createExpert(t)
+	client := env.createUser(t, "user")
+
+	// Looked up twice: once from the database, once from the cache
+	for i := 0; i < 2; i++ {
+		id, err := env.experts.ExpertIDForUser(expertUser.ID)
+		if err != nil {
+			t.Fatalf("ExpertIDForUser: %v", err)
+		}
+		if id != expert.ID {
+			t.Fatalf("got expert %d for user %d, want %d", id, expertUser.ID, expert.ID)
+		}
+	}
+
+	if _, err := env.experts.ExpertIDForUser(client.ID); !errors.Is(err, ErrNoExpertProfile) {
+		t.Fatalf("got %v for a user without a profile, want ErrNoExpertProfile", err)
+	}
+}
+
+func TestExpertActsOnOwnBookingByUserID(t *testing.T) {
+	env := newTestEnv(t)
+	expertUser, expert := env.createExpert(t)
+	client := env.createUser(t, "user")
+	booking := env.book(t, client.ID, expert.ID, futureHour(48))
+
+	confirmed, err := env.bookings.TransitionBooking(booking.ID, actorFor(expertUser), models.BookingStatusConfirmed, "")
+	if err != nil {
+		t.Fatalf("expert confirming own booking: %v", err)
+	}
+	if confirmed.Status != models.BookingStatusConfirmed {
+		t.Fatalf("status %q, want %q", confirmed.Status, models.BookingStatusConfirmed)
+	}
+
+	// An account whose user ID equals the expert ID is somebody else
+	impostor := Actor{UserID: expert.ID, Role: "expert"}
+	if impostor.UserID == expertUser.ID {
+		t.Fatal("expert ID and user ID must differ for this test")
+	}
+	if _, err := env.bookings.GetBooking(booking.ID, impostor); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("user %d viewing expert %d's booking: got %v, want ErrForbidden", impostor.UserID, expert.ID, err)
+	}
+
+	bookings, err := env.experts.GetExpertBookings(expert.ID)
+	if err != nil {
+		t.Fatalf("GetExpertBookings: %v", err)
+	}
+	if len(bookings) != 1 || bookings[0].ID != booking.ID {
+		t.Fatalf("expert bookings %v, want only booking %d", bookings, booking.ID)
+	}
+}
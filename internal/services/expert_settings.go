@@ -0,0 +1,271 @@
+// internal/services/expert_settings.go
+package services
+
+import (
+	"consultation-booking/internal/config"
+	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookingNoticeTooShort = errors.New("session starts too soon to book")
+	ErrBookingTooFarAhead    = errors.New("session is too far ahead to book")
+	ErrBufferConflict        = errors.New("session is too close to another session")
+	ErrDailyLimitReached     = errors.New("expert has no more sessions available that day")
+)
+
+// Codes returned with scheduling errors so API clients can tell them apart
+// without parsing messages
+const (
+	CodeBookingNoticeTooShort = "booking_notice_too_short"
+	CodeBookingTooFarAhead    = "booking_too_far_ahead"
+	CodeBufferConflict        = "buffer_conflict"
+	CodeDailyLimitReached     = "daily_limit_reached"
+)
+
+// SchedulingError is a booking refused by the expert's scheduling rules.
+type SchedulingError struct {
+	Code   string
+	Err    error
+	Detail string
+}
+
+func (e *SchedulingError) Error() string {
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *SchedulingError) Unwrap() error {
+	return e.Err
+}
+
+type ExpertSettingsRequest struct {
+	BufferBeforeMinutes int `json:"buffer_before_minutes" binding:"min=0"`
+	BufferAfterMinutes  int `json:"buffer_after_minutes" binding:"min=0"`
+	MinNoticeMinutes    int `json:"min_notice_minutes" binding:"min=0"`
+	MaxHorizonDays      int `json:"max_horizon_days" binding:"min=0"`
+	MaxSessionsPerDay   int `json:"max_sessions_per_day" binding:"min=0"`
+}
+
+// GetSettings returns the scheduling rules that apply to the expert's
+// bookings: their own, or the platform defaults.
+func (s *ExpertService) GetSettings(expertID uint) (*models.ExpertSettings, error) {
+	return loadExpertSettings(s.db, expertID, s.policy.Scheduling)
+}
+
+// UpdateSettings sets the expert's own scheduling rules.
+func (s *ExpertService) UpdateSettings(expertID uint, req ExpertSettingsRequest) (*models.ExpertSettings, error) {
+	var settings models.ExpertSettings
+	if err := s.db.Where("expert_id = ?", expertID).FirstOrInit(&settings).Error; err != nil {
+		return nil, err
+	}
+
+	settings.ExpertID = expertID
+	settings.BufferBeforeMinutes = req.BufferBeforeMinutes
+	settings.BufferAfterMinutes = req.BufferAfterMinutes
+	settings.MinNoticeMinutes = req.MinNoticeMinutes
+	settings.MaxHorizonDays = req.MaxHorizonDays
+	settings.MaxSessionsPerDay = req.MaxSessionsPerDay
+
+	if err := s.db.Save(&settings).Error; err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// ResetSettings drops the expert's own scheduling rules so the platform
+// defaults apply again.
+func (s *ExpertService) ResetSettings(expertID uint) error {
+	return s.db.Where("expert_id = ?", expertID).Delete(&models.ExpertSettings{}).Error
+}
+
+func defaultExpertSettings(cfg config.SchedulingConfig) *models.ExpertSettings {
+	return &models.ExpertSettings{
+		BufferBeforeMinutes: int(cfg.BufferBefore.Minutes()),
+		BufferAfterMinutes:  int(cfg.BufferAfter.Minutes()),
+		MinNoticeMinutes:    int(cfg.MinNotice.Minutes()),
+		MaxHorizonDays:      int(cfg.MaxHorizon.Hours() / 24),
+		MaxSessionsPerDay:   cfg.MaxSessionsPerDay,
+		IsDefault:           true,
+	}
+}
+
+func loadExpertSettings(db *gorm.DB, expertID uint, defaults config.SchedulingConfig) (*models.ExpertSettings, error) {
+	var settings models.ExpertSettings
+	err := db.Where("expert_id = ?", expertID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultExpertSettings(defaults), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// bookingWindow is the earliest and latest start the settings allow at
+// now. latest is zero when there is no horizon.
+func bookingWindow(settings *models.ExpertSettings, now time.Time) (earliest, latest time.Time) {
+	earliest = now.Add(time.Duration(settings.MinNoticeMinutes) * time.Minute)
+	if settings.MaxHorizonDays > 0 {
+		latest = now.AddDate(0, 0, settings.MaxHorizonDays)
+	}
+	return earliest, latest
+}
+
+// checkSchedulingRules enforces the expert's settings on a session from
+// start to end in slot. Bookings already in the slot share the session and
+// don't count against it; excludeID is a booking being moved.
+func checkSchedulingRules(tx *gorm.DB, settings *models.ExpertSettings, slot *models.AvailableSlot, start, end time.Time, excludeID uint) error {
+	earliest, latest := bookingWindow(settings, time.Now())
+	if start.Before(earliest) {
+		return &SchedulingError{
+			Code:   CodeBookingNoticeTooShort,
+			Err:    ErrBookingNoticeTooShort,
+			Detail: fmt.Sprintf("sessions must be booked at least %d minutes ahead", settings.MinNoticeMinutes),
+		}
+	}
+	if !latest.IsZero() && start.After(latest) {
+		return &SchedulingError{
+			Code:   CodeBookingTooFarAhead,
+			Err:    ErrBookingTooFarAhead,
+			Detail: fmt.Sprintf("sessions can be booked at most %d days ahead", settings.MaxHorizonDays),
+		}
+	}
+
+	before := time.Duration(settings.BufferBeforeMinutes) * time.Minute
+	after := time.Duration(settings.BufferAfterMinutes) * time.Minute
+	if before > 0 || after > 0 {
+		var nearby int64
+		if err := tx.Model(&models.Booking{}).Where(
+			"expert_id = ? AND id <> ? AND (slot_id IS NULL OR slot_id <> ?) AND status IN (?) AND start_time < ? AND end_time > ?",
+			slot.ExpertID, excludeID, slot.ID, activeBookingStatuses, end.Add(after), start.Add(-before),
+		).Count(&nearby).Error; err != nil {
+			return err
+		}
+		if nearby > 0 {
+			return &SchedulingError{
+				Code:   CodeBufferConflict,
+				Err:    ErrBufferConflict,
+				Detail: fmt.Sprintf("sessions need %d minutes free before and %d minutes after", settings.BufferBeforeMinutes, settings.BufferAfterMinutes),
+			}
+		}
+	}
+
+	// Joining a session that already has attendees adds none
+	if settings.MaxSessionsPerDay > 0 && slot.BookedCount == 0 {
+		var expert models.Expert
+		if err := tx.Select("timezone").First(&expert, slot.ExpertID).Error; err != nil {
+			return err
+		}
+		dayStart, dayEnd := localDay(start, locationOrUTC(expert.Timezone))
+
+		// Attendees of a group session count as one session
+		var sessions int64
+		if err := tx.Model(&models.Booking{}).Where(
+			"expert_id = ? AND id <> ? AND status IN (?) AND start_time >= ? AND start_time < ?",
+			slot.ExpertID, excludeID, activeBookingStatuses, dayStart, dayEnd,
+		).Distinct("slot_id").Count(&sessions).Error; err != nil {
+			return err
+		}
+		if sessions >= int64(settings.MaxSessionsPerDay) {
+			return &SchedulingError{
+				Code:   CodeDailyLimitReached,
+				Err:    ErrDailyLimitReached,
+				Detail: fmt.Sprintf("at most %d sessions per day", settings.MaxSessionsPerDay),
+			}
+		}
+	}
+	return nil
+}
+
+// applySchedulingRules drops the slots the expert's settings wouldn't let
+// a client book: too soon, too far ahead, too close to a booked session or
+// on a day that is already full.
+func (s *ExpertService) applySchedulingRules(expertID uint, slots []models.AvailableSlot) ([]models.AvailableSlot, error) {
+	if len(slots) == 0 {
+		return slots, nil
+	}
+
+	settings, err := loadExpertSettings(s.db, expertID, s.policy.Scheduling)
+	if err != nil {
+		return nil, err
+	}
+	earliest, latest := bookingWindow(settings, time.Now())
+	before := time.Duration(settings.BufferBeforeMinutes) * time.Minute
+	after := time.Duration(settings.BufferAfterMinutes) * time.Minute
+
+	var bookings []models.Booking
+	loc := time.UTC
+	if before > 0 || after > 0 || settings.MaxSessionsPerDay > 0 {
+		var expert models.Expert
+		if err := s.db.Select("timezone").First(&expert, expertID).Error; err != nil {
+			return nil, err
+		}
+		loc = locationOrUTC(expert.Timezone)
+
+		// Sessions earlier today still count towards today's cap
+		from := earliest.Add(-before)
+		if today, _ := localDay(time.Now(), loc); today.Before(from) {
+			from = today
+		}
+		if err := s.db.Where("expert_id = ? AND status IN (?) AND end_time > ?",
+			expertID, activeBookingStatuses, from).
+			Find(&bookings).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Sessions per local day, counting a group session once
+	sessionsPerDay := make(map[string]int)
+	attended := make(map[uint]bool)
+	for _, b := range bookings {
+		if b.SlotID != nil {
+			if attended[*b.SlotID] {
+				continue
+			}
+			attended[*b.SlotID] = true
+		}
+		sessionsPerDay[b.StartTime.In(loc).Format(dateLayout)]++
+	}
+
+	bookable := make([]models.AvailableSlot, 0, len(slots))
+	for _, slot := range slots {
+		if slot.StartTime.Before(earliest) || (!latest.IsZero() && slot.StartTime.After(latest)) {
+			continue
+		}
+
+		if before > 0 || after > 0 {
+			tooClose := false
+			for _, b := range bookings {
+				if b.SlotID != nil && *b.SlotID == slot.ID {
+					continue
+				}
+				if b.StartTime.Before(slot.EndTime.Add(after)) && b.EndTime.After(slot.StartTime.Add(-before)) {
+					tooClose = true
+					break
+				}
+			}
+			if tooClose {
+				continue
+			}
+		}
+
+		if settings.MaxSessionsPerDay > 0 && !attended[slot.ID] &&
+			sessionsPerDay[slot.StartTime.In(loc).Format(dateLayout)] >= settings.MaxSessionsPerDay {
+			continue
+		}
+
+		bookable = append(bookable, slot)
+	}
+	return bookable, nil
+}
+
+// localDay is the UTC range of the calendar day containing t in loc.
+func localDay(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return start.UTC(), start.AddDate(0, 0, 1).UTC()
+}
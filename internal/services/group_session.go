@@ -0,0 +1,42 @@
+// internal/services/group_session.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+)
+
+var ErrSlotNotFound = errors.New("slot not found")
+
+// SlotAttendees is a slot together with the clients booked into it.
+type SlotAttendees struct {
+	Slot      models.AvailableSlot `json:"slot"`
+	Attendees []models.Booking     `json:"attendees"`
+}
+
+// GetSlotAttendees lists the active bookings of one of the expert's slots.
+func (s *ExpertService) GetSlotAttendees(expertID, slotID uint) (*SlotAttendees, error) {
+	var slot models.AvailableSlot
+	if err := s.db.Where("id = ? AND expert_id = ?", slotID, expertID).First(&slot).Error; err != nil {
+		return nil, ErrSlotNotFound
+	}
+	slot.RemainingSeats = slot.Capacity - slot.BookedCount
+
+	var bookings []models.Booking
+	if err := s.db.Where("slot_id = ? AND status IN ?", slot.ID, activeBookingStatuses).
+		Preload("User").
+		Order("created_at").
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	return &SlotAttendees{Slot: slot, Attendees: bookings}, nil
+}
+
+// seatCount defaults an unset capacity to a one-to-one session.
+func seatCount(capacity int) int {
+	if capacity < 1 {
+		return 1
+	}
+	return capacity
+}
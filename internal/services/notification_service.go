@@ -3,11 +3,15 @@ package services
 
 import (
 	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
+var ErrNotificationNotFound = errors.New("notification not found")
+
 type NotificationService struct {
 	db    *gorm.DB
 	redis *redis.Client
@@ -21,14 +25,137 @@ func NewNotificationService(db *gorm.DB, redis *redis.Client) *NotificationServi
 }
 
 func (s *NotificationService) CreateNotification(userID uint, title, message, notificationType string) error {
-	notification := models.Notification{
-		UserID:  userID,
-		Title:   title,
-		Message: message,
-		Type:    notificationType,
+	return createNotification(s.db, userID, title, message, notificationType)
+}
+
+// BookingTransitionHook notifies the parties of a booking about a status
+// change. It is registered with BookingService.OnTransition.
+func (s *NotificationService) BookingTransitionHook(tx *gorm.DB, t BookingTransition) error {
+	booking := t.Booking
+	clientID := booking.UserID
+	expertUserID := booking.Expert.UserID
+
+	// Each party sees the start time in their own zone
+	clientWhen := FormatInZone(booking.StartTime, booking.User.Timezone)
+	expertWhen := FormatInZone(booking.StartTime, booking.Expert.Timezone)
+
+	switch t.To {
+	case models.BookingStatusConfirmed:
+		return createNotification(tx, clientID, "Booking Confirmed",
+			fmt.Sprintf("Your consultation with %s on %s has been confirmed", booking.Expert.User.Name, clientWhen), "booking")
+
+	case models.BookingStatusRejected:
+		return createNotification(tx, clientID, "Booking Rejected",
+			fmt.Sprintf("Your consultation with %s on %s was rejected", booking.Expert.User.Name, clientWhen), "booking")
+
+	case models.BookingStatusCancelled:
+		return notifyCancelled(tx, t)
+	case models.BookingStatusMissed:
+		if err := createNotification(tx, clientID, "Booking Missed",
+			fmt.Sprintf("Your consultation with %s on %s was never confirmed and has expired", booking.Expert.User.Name, clientWhen), "booking"); err != nil {
+			return err
+		}
+		return createNotification(tx, expertUserID, "Booking Missed",
+			fmt.Sprintf("The consultation request from %s on %s expired without confirmation", booking.User.Name, expertWhen), "booking")
+
+	case models.BookingStatusNoShow:
+		// A late cancellation recorded as a no-show is still announced as
+		// a cancellation
+		if booking.LateCancellation && t.Role == ActorRoleClient {
+			return notifyCancelled(tx, t)
+		}
+		return createNotification(tx, clientID, "Consultation Missed",
+			fmt.Sprintf("You were marked as absent for your consultation with %s on %s", booking.Expert.User.Name, clientWhen), "booking")
+
+	case models.BookingStatusCompleted:
+		return createNotification(tx, clientID, "Consultation Completed",
+			fmt.Sprintf("Your consultation with %s has been completed", booking.Expert.User.Name), "booking")
+	}
+
+	return nil
+}
+
+// notifyCancelled tells the parties of a booking it was cancelled. Nobody
+// hears of their own cancellation unless it was late, when both parties are
+// told since it can carry a no-show or a fee.
+func notifyCancelled(tx *gorm.DB, t BookingTransition) error {
+	booking := t.Booking
+	suffix := ""
+	if booking.LateCancellation {
+		suffix = " after the cancellation cutoff"
+	}
+
+	if t.Role != ActorRoleClient || booking.LateCancellation {
+		if err := createNotification(tx, booking.UserID, "Booking Cancelled",
+			fmt.Sprintf("Your consultation with %s on %s has been cancelled%s",
+				booking.Expert.User.Name, FormatInZone(booking.StartTime, booking.User.Timezone), suffix), "cancellation"); err != nil {
+			return err
+		}
+	}
+	if t.Role != ActorRoleExpert || booking.LateCancellation {
+		return createNotification(tx, booking.Expert.UserID, "Booking Cancelled",
+			fmt.Sprintf("Your consultation with %s on %s has been cancelled%s",
+				booking.User.Name, FormatInZone(booking.StartTime, booking.Expert.Timezone), suffix), "cancellation")
+	}
+	return nil
+}
+
+// BookingRescheduleHook tells the parties of a booking about reschedule
+// requests and their outcome. It is registered with
+// BookingService.OnReschedule.
+func (s *NotificationService) BookingRescheduleHook(tx *gorm.DB, e BookingRescheduleEvent) error {
+	booking := e.Booking
+	reschedule := e.Reschedule
+
+	parties := []struct {
+		userID   uint
+		other    string
+		timezone string
+	}{
+		{booking.UserID, booking.Expert.User.Name, booking.User.Timezone},
+		{booking.Expert.UserID, booking.User.Name, booking.Expert.Timezone},
+	}
+
+	for _, p := range parties {
+		// Nobody is notified of their own action
+		if p.userID == e.Actor.UserID {
+			continue
+		}
+
+		from := FormatInZone(reschedule.OldStartTime, p.timezone)
+		to := FormatInZone(reschedule.NewStartTime, p.timezone)
+
+		var title, message string
+		switch e.Event {
+		case RescheduleRequested:
+			title = "Reschedule Requested"
+			message = fmt.Sprintf("%s asked to move your consultation on %s to %s", p.other, from, to)
+
+		case RescheduleApplied:
+			title = "Booking Rescheduled"
+			message = fmt.Sprintf("Your consultation with %s has been moved from %s to %s", p.other, from, to)
+
+		case RescheduleDeclined:
+			if e.Actor.UserID == reschedule.RequestedBy {
+				title = "Reschedule Withdrawn"
+				message = fmt.Sprintf("%s withdrew the request to move your consultation on %s", p.other, from)
+			} else if p.userID == reschedule.RequestedBy {
+				title = "Reschedule Declined"
+				message = fmt.Sprintf("Your request to move your consultation with %s to %s was declined", p.other, to)
+			} else {
+				continue
+			}
+
+		default:
+			continue
+		}
+
+		if err := createNotification(tx, p.userID, title, message, "reschedule"); err != nil {
+			return err
+		}
 	}
 
-	return s.db.Create(&notification).Error
+	return nil
 }
 
 func (s *NotificationService) GetUserNotifications(userID uint, limit int) ([]models.Notification, error) {
@@ -40,8 +167,18 @@ func (s *NotificationService) GetUserNotifications(userID uint, limit int) ([]mo
 	return notifications, err
 }
 
-func (s *NotificationService) MarkAsRead(notificationID uint) error {
-	return s.db.Model(&models.Notification{}).Where("id = ?", notificationID).Update("is_read", true).Error
+// MarkAsRead marks a notification as read. Only its recipient may do so.
+func (s *NotificationService) MarkAsRead(notificationID uint, actor Actor) error {
+	var notification models.Notification
+	if err := s.db.First(&notification, notificationID).Error; err != nil {
+		return ErrNotificationNotFound
+	}
+
+	if err := Authorize(actor, ActionMarkRead, &notification); err != nil {
+		return err
+	}
+
+	return s.db.Model(&notification).Update("is_read", true).Error
 }
 
 func (s *NotificationService) GetUnreadCount(userID uint) (int64, error) {
@@ -49,3 +186,14 @@ func (s *NotificationService) GetUnreadCount(userID uint) (int64, error) {
 	err := s.db.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error
 	return count, err
 }
+
+func createNotification(db *gorm.DB, userID uint, title, message, notificationType string) error {
+	notification := models.Notification{
+		UserID:  userID,
+		Title:   title,
+		Message: message,
+		Type:    notificationType,
+	}
+
+	return db.Create(&notification).Error
+}
@@ -0,0 +1,94 @@
+// internal/services/onetime_token.go
+package services
+
+import (
+	"consultation-booking/internal/auth"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+
+// One-time tokens are emailed to users (password reset, email
+// verification). Only a SHA-256 of the token is stored, under
+// "<purpose>:<hash>", and issuing a new token for a user invalidates the
+// previous one. A token can be bound to a value, such as the address it was
+// sent to, which is handed back when it is consumed.
+
+func issueOneTimeToken(ctx context.Context, rdb *redis.Client, purpose string, userID uint, bound string, ttl time.Duration) (string, error) {
+	token := auth.NewTokenID() + auth.NewTokenID()
+	hash := hashToken(token)
+
+	if err := revokeOneTimeToken(ctx, rdb, purpose, userID); err != nil {
+		return "", err
+	}
+
+	value := strconv.FormatUint(uint64(userID), 10)
+	if bound != "" {
+		value += ":" + bound
+	}
+
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, purpose+":"+hash, value, ttl)
+	pipe.Set(ctx, oneTimeTokenUserKey(purpose, userID), hash, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeOneTimeToken atomically deletes the token and returns the user it
+// was issued to and the value it was bound to, so a token can be used once
+// only.
+func consumeOneTimeToken(ctx context.Context, rdb *redis.Client, purpose, token string) (uint, string, error) {
+	value, err := rdb.GetDel(ctx, purpose+":"+hashToken(token)).Result()
+	if err == redis.Nil {
+		return 0, "", ErrInvalidOneTimeToken
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidOneTimeToken
+	}
+	bound := ""
+	if len(parts) == 2 {
+		bound = parts[1]
+	}
+
+	rdb.Del(ctx, oneTimeTokenUserKey(purpose, uint(userID)))
+	return uint(userID), bound, nil
+}
+
+// revokeOneTimeToken invalidates the user's outstanding token, if any.
+func revokeOneTimeToken(ctx context.Context, rdb *redis.Client, purpose string, userID uint) error {
+	userKey := oneTimeTokenUserKey(purpose, userID)
+	previous, err := rdb.Get(ctx, userKey).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return rdb.Del(ctx, purpose+":"+previous, userKey).Err()
+}
+
+func oneTimeTokenUserKey(purpose string, userID uint) string {
+	return fmt.Sprintf("%s_user:%d", purpose, userID)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
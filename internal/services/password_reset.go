@@ -0,0 +1,91 @@
+// internal/services/password_reset.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetPurpose = "password_reset"
+	passwordResetTTL     = 30 * time.Minute
+
+	// At most this many reset emails per address per window
+	passwordResetLimit  = 3
+	passwordResetWindow = time.Hour
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// ForgotPassword emails a reset link if the address belongs to an active
+// account. The caller always gets the same answer, and the lookup and
+// email happen in the background so response time doesn't reveal whether
+// the account exists.
+func (s *UserService) ForgotPassword(req ForgotPasswordRequest) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	ctx := context.Background()
+	rateKey := "password_reset_rate:" + hashToken(email)
+	count, err := s.redis.Incr(ctx, rateKey).Result()
+	if err != nil {
+		log.Printf("Password reset rate limit unavailable: %v", err)
+		return
+	}
+	if count == 1 {
+		s.redis.Expire(ctx, rateKey, passwordResetWindow)
+	}
+	if count > passwordResetLimit {
+		return
+	}
+
+	go s.sendPasswordReset(email)
+}
+
+func (s *UserService) sendPasswordReset(email string) {
+	var user models.User
+	if err := s.db.Where("LOWER(email) = ?", email).First(&user).Error; err != nil || !user.IsActive {
+		return
+	}
+
+	token, err := issueOneTimeToken(context.Background(), s.redis, passwordResetPurpose, user.ID, "", passwordResetTTL)
+	if err != nil {
+		log.Printf("Failed to issue password reset token: %v", err)
+		return
+	}
+
+	if err := s.emailService.SendPasswordReset(user.Email, user.Name, token); err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+}
+
+// ResetPassword sets a new password with a reset token and signs the user
+// out everywhere.
+func (s *UserService) ResetPassword(req ResetPasswordRequest) error {
+	userID, _, err := consumeOneTimeToken(context.Background(), s.redis, passwordResetPurpose, req.Token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("password", string(hashedPassword)).Error; err != nil {
+		return err
+	}
+
+	return s.LogoutAll(userID)
+}
@@ -0,0 +1,65 @@
+// internal/services/policy.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+)
+
+// Actions checked by Authorize
+const (
+	ActionView         = "view"
+	ActionCancel       = "cancel"
+	ActionReschedule   = "reschedule"
+	ActionUpdateStatus = "update_status"
+	ActionMarkRead     = "mark_read"
+)
+
+var ErrForbidden = errors.New("not permitted to access this resource")
+
+// bookingPolicy maps action -> roles (as resolved by bookingRole) allowed
+// to perform it on a booking. Status changes are further restricted by the
+// lifecycle table in booking_lifecycle.go.
+var bookingPolicy = map[string][]string{
+	ActionView:         {ActorRoleClient, ActorRoleExpert, ActorRoleAdmin, ActorRoleSystem},
+	ActionCancel:       {ActorRoleClient, ActorRoleExpert, ActorRoleAdmin},
+	ActionReschedule:   {ActorRoleClient, ActorRoleExpert, ActorRoleAdmin},
+	ActionUpdateStatus: {ActorRoleExpert, ActorRoleAdmin, ActorRoleSystem},
+}
+
+// notificationPolicy lists the actions the recipient of a notification may
+// perform. Nobody else, admins included, can touch it.
+var notificationPolicy = map[string]bool{
+	ActionView:     true,
+	ActionMarkRead: true,
+}
+
+// Authorize decides whether actor may perform action on resource. Bookings
+// and booking series must have Expert loaded; series follow the booking
+// policy. It returns ErrForbidden when the actor may not.
+func Authorize(actor Actor, action string, resource interface{}) error {
+	switch r := resource.(type) {
+	case *models.Booking:
+		role := bookingRole(r, actor)
+		for _, allowed := range bookingPolicy[action] {
+			if role != "" && role == allowed {
+				return nil
+			}
+		}
+
+	case *models.BookingSeries:
+		role := partyRole(r.UserID, r.Expert.UserID, actor)
+		for _, allowed := range bookingPolicy[action] {
+			if role != "" && role == allowed {
+				return nil
+			}
+		}
+
+	case *models.Notification:
+		if notificationPolicy[action] && r.UserID == actor.UserID {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
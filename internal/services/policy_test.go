@@ -0,0 +1,136 @@
+// internal/services/policy_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBookingAuthorizationMatrix runs every booking action as each party,
+// as an unrelated client and as an expert who has nothing to do with the
+// booking.
+func TestBookingAuthorizationMatrix(t *testing.T) {
+	env := newTestEnv(t)
+	expertUser, expert := env.createExpert(t)
+	otherExpertUser, _ := env.createExpert(t)
+	client := env.createUser(t, "user")
+	otherClient := env.createUser(t, "user")
+	admin := env.createUser(t, "admin")
+
+	actors := map[string]Actor{
+		"client":       actorFor(client),
+		"expert":       actorFor(expertUser),
+		"other client": actorFor(otherClient),
+		"other expert": actorFor(otherExpertUser),
+		"admin":        actorFor(admin),
+	}
+
+	actions := []struct {
+		name    string
+		allowed map[string]bool
+		run     func(booking *models.Booking, actor Actor) error
+	}{
+		{
+			name:    ActionView,
+			allowed: map[string]bool{"client": true, "expert": true, "admin": true},
+			run: func(booking *models.Booking, actor Actor) error {
+				_, err := env.bookings.GetBooking(booking.ID, actor)
+				return err
+			},
+		},
+		{
+			name:    ActionCancel,
+			allowed: map[string]bool{"client": true, "expert": true, "admin": true},
+			run: func(booking *models.Booking, actor Actor) error {
+				_, err := env.bookings.CancelBooking(booking.ID, actor, "")
+				return err
+			},
+		},
+		{
+			name:    ActionReschedule,
+			allowed: map[string]bool{"client": true, "expert": true, "admin": true},
+			run: func(booking *models.Booking, actor Actor) error {
+				target := env.createSlot(t, expert.ID, booking.StartTime.Add(24*time.Hour), time.Hour)
+				_, err := env.bookings.RescheduleBooking(booking.ID, actor, RescheduleBookingRequest{
+					StartTime: target.StartTime,
+					EndTime:   target.EndTime,
+				})
+				return err
+			},
+		},
+		{
+			name:    ActionUpdateStatus,
+			allowed: map[string]bool{"expert": true, "admin": true},
+			run: func(booking *models.Booking, actor Actor) error {
+				_, err := env.bookings.TransitionBooking(booking.ID, actor, models.BookingStatusConfirmed, "")
+				return err
+			},
+		},
+	}
+
+	hour := 48
+	for _, action := range actions {
+		for name, actor := range actors {
+			// Each case gets its own booking, two hours apart
+			hour += 2
+			booking := env.book(t, client.ID, expert.ID, futureHour(hour))
+
+			err := action.run(booking, actor)
+			switch {
+			case action.allowed[name] && err != nil:
+				t.Errorf("%s as %s: %v", action.name, name, err)
+			case !action.allowed[name] && !errors.Is(err, ErrForbidden):
+				t.Errorf("%s as %s: got %v, want ErrForbidden", action.name, name, err)
+			}
+		}
+	}
+
+	if _, err := env.bookings.GetBooking(999999, actorFor(admin)); !errors.Is(err, ErrBookingNotFound) {
+		t.Errorf("viewing a missing booking: got %v, want ErrBookingNotFound", err)
+	}
+}
+
+// TestNotificationAuthorizationMatrix checks that only the recipient can
+// mark a notification read, admins included.
+func TestNotificationAuthorizationMatrix(t *testing.T) {
+	env := newTestEnv(t)
+	notifications := NewNotificationService(env.db, env.redis)
+	expertUser, _ := env.createExpert(t)
+	client := env.createUser(t, "user")
+	otherClient := env.createUser(t, "user")
+	admin := env.createUser(t, "admin")
+
+	tests := []struct {
+		name  string
+		actor Actor
+		want  error
+	}{
+		{"client", actorFor(client), nil},
+		{"expert", actorFor(expertUser), ErrForbidden},
+		{"other client", actorFor(otherClient), ErrForbidden},
+		{"admin", actorFor(admin), ErrForbidden},
+	}
+	for _, tt := range tests {
+		notification := models.Notification{UserID: client.ID, Title: "Booking", Message: "Booked"}
+		if err := env.db.Create(&notification).Error; err != nil {
+			t.Fatalf("creating notification: %v", err)
+		}
+
+		err := notifications.MarkAsRead(notification.ID, tt.actor)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s as %s: got %v, want %v", ActionMarkRead, tt.name, err, tt.want)
+		}
+
+		var stored models.Notification
+		env.db.First(&stored, notification.ID)
+		if stored.IsRead != (tt.want == nil) {
+			t.Errorf("%s as %s: is_read = %v", ActionMarkRead, tt.name, stored.IsRead)
+		}
+	}
+
+	if err := notifications.MarkAsRead(999999, actorFor(client)); !errors.Is(err, ErrNotificationNotFound) {
+		t.Errorf("marking a missing notification: got %v, want ErrNotificationNotFound", err)
+	}
+}
@@ -0,0 +1,102 @@
+// internal/services/session_type.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionTypeNotFound = errors.New("session type not found")
+	ErrSessionTypeDuration = errors.New("end time doesn't match the session type's duration")
+)
+
+type SessionTypeRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Description     string `json:"description"`
+	DurationMinutes int    `json:"duration_minutes" binding:"required,min=5,max=720"`
+	PriceCents      int64  `json:"price_cents" binding:"min=0"`
+	Currency        string `json:"currency"` // ISO 4217, USD when omitted
+	IsActive        *bool  `json:"is_active"`
+}
+
+// GetSessionTypes returns the expert's session types. Clients only see the
+// active ones.
+func (s *ExpertService) GetSessionTypes(expertID uint, includeInactive bool) ([]models.SessionType, error) {
+	query := s.db.Where("expert_id = ?", expertID)
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var sessionTypes []models.SessionType
+	err := query.Order("duration_minutes, id").Find(&sessionTypes).Error
+	return sessionTypes, err
+}
+
+func (s *ExpertService) CreateSessionType(expertID uint, req SessionTypeRequest) (*models.SessionType, error) {
+	sessionType := models.SessionType{ExpertID: expertID}
+	applySessionTypeRequest(&sessionType, req)
+
+	if err := s.db.Create(&sessionType).Error; err != nil {
+		return nil, err
+	}
+
+	// Inactive is the zero value, so the column default would override it
+	if !sessionType.IsActive {
+		if err := s.db.Model(&sessionType).Update("is_active", false).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &sessionType, nil
+}
+
+// UpdateSessionType changes one of the expert's session types. Bookings
+// already made keep the price they were made at.
+func (s *ExpertService) UpdateSessionType(expertID, sessionTypeID uint, req SessionTypeRequest) (*models.SessionType, error) {
+	var sessionType models.SessionType
+	if err := s.db.Where("id = ? AND expert_id = ?", sessionTypeID, expertID).First(&sessionType).Error; err != nil {
+		return nil, ErrSessionTypeNotFound
+	}
+	applySessionTypeRequest(&sessionType, req)
+
+	if err := s.db.Save(&sessionType).Error; err != nil {
+		return nil, err
+	}
+	return &sessionType, nil
+}
+
+func (s *ExpertService) DeleteSessionType(expertID, sessionTypeID uint) error {
+	result := s.db.Where("id = ? AND expert_id = ?", sessionTypeID, expertID).Delete(&models.SessionType{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionTypeNotFound
+	}
+	return nil
+}
+
+func applySessionTypeRequest(sessionType *models.SessionType, req SessionTypeRequest) {
+	sessionType.Name = strings.TrimSpace(req.Name)
+	sessionType.Description = req.Description
+	sessionType.DurationMinutes = req.DurationMinutes
+	sessionType.PriceCents = req.PriceCents
+	sessionType.Currency = strings.ToUpper(req.Currency)
+	if sessionType.Currency == "" {
+		sessionType.Currency = "USD"
+	}
+	sessionType.IsActive = req.IsActive == nil || *req.IsActive
+}
+
+// findSessionType loads an active session type offered by the expert.
+func findSessionType(tx *gorm.DB, expertID, sessionTypeID uint) (*models.SessionType, error) {
+	var sessionType models.SessionType
+	if err := tx.Where("id = ? AND expert_id = ? AND is_active = ?", sessionTypeID, expertID, true).
+		First(&sessionType).Error; err != nil {
+		return nil, ErrSessionTypeNotFound
+	}
+	return &sessionType, nil
+}
@@ -0,0 +1,167 @@
+// internal/services/setup_test.go
+package services
+
+import (
+	"consultation-booking/internal/config"
+	"consultation-booking/internal/models"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a migrated SQLite database private to the test. Write
+// transactions take the database lock when they begin, so concurrent ones
+// are serialized the way row locks serialize them on Postgres.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_txlock=immediate&_busy_timeout=10000&_foreign_keys=off"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Expert{},
+		&models.ExpertLanguage{},
+		&models.Booking{},
+		&models.BookingSeries{},
+		&models.Notification{},
+		&models.Feedback{},
+		&models.AvailableSlot{},
+		&models.AvailabilityRule{},
+		&models.AvailabilityOverride{},
+		&models.RecoveryCode{},
+		&models.BookingReschedule{},
+		&models.CancellationPolicy{},
+		&models.ExpertSettings{},
+		&models.SessionType{},
+		&models.WaitlistEntry{},
+		&models.WaitlistOffer{},
+	); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	return db
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// testEnv holds the services under test, wired the way main.go wires them.
+type testEnv struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	bookings *BookingService
+	experts  *ExpertService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	db := newTestDB(t)
+	rdb := newTestRedis(t)
+	policy := config.BookingConfig{
+		Cancellation: config.CancellationConfig{LateOutcome: models.LateCancellationBlock},
+	}
+
+	bookings := NewBookingService(db, rdb, policy)
+	bookings.OnTransition(NewNotificationService(db, rdb).BookingTransitionHook)
+
+	return &testEnv{
+		db:       db,
+		redis:    rdb,
+		bookings: bookings,
+		experts:  NewExpertService(db, rdb, policy),
+	}
+}
+
+func (e *testEnv) createUser(t *testing.T, role string) models.User {
+	t.Helper()
+
+	var count int64
+	e.db.Model(&models.User{}).Count(&count)
+	user := models.User{
+		Email:    fmt.Sprintf("user%d@example.com", count+1),
+		Password: "x",
+		Name:     fmt.Sprintf("User %d", count+1),
+		Role:     role,
+		IsActive: true,
+	}
+	if err := e.db.Create(&user).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return user
+}
+
+// createExpert adds an expert whose user ID differs from the expert ID, so
+// tests catch one being used for the other.
+func (e *testEnv) createExpert(t *testing.T) (models.User, models.Expert) {
+	t.Helper()
+
+	e.createUser(t, "user")
+	user := e.createUser(t, "expert")
+	expert := models.Expert{UserID: user.ID, Speciality: "Law", IsAvailable: true}
+	if err := e.db.Create(&expert).Error; err != nil {
+		t.Fatalf("creating expert: %v", err)
+	}
+	if expert.ID == user.ID {
+		t.Fatalf("expert %d shares its ID with its user", expert.ID)
+	}
+	return user, expert
+}
+
+func (e *testEnv) createSlot(t *testing.T, expertID uint, start time.Time, length time.Duration) models.AvailableSlot {
+	t.Helper()
+
+	slot := models.AvailableSlot{
+		ExpertID:  expertID,
+		StartTime: start.UTC(),
+		EndTime:   start.Add(length).UTC(),
+		Capacity:  1,
+	}
+	if err := e.db.Create(&slot).Error; err != nil {
+		t.Fatalf("creating slot: %v", err)
+	}
+	return slot
+}
+
+// book creates a pending booking of a fresh slot for the client.
+func (e *testEnv) book(t *testing.T, clientID, expertID uint, start time.Time) *models.Booking {
+	t.Helper()
+
+	slot := e.createSlot(t, expertID, start, time.Hour)
+	booking, err := e.bookings.CreateBooking(clientID, CreateBookingRequest{
+		ExpertID:  expertID,
+		StartTime: slot.StartTime,
+		EndTime:   slot.EndTime,
+	})
+	if err != nil {
+		t.Fatalf("booking slot: %v", err)
+	}
+	return booking
+}
+
+// futureHour is the start of the hour the given number of hours from now.
+func futureHour(hours int) time.Time {
+	return time.Now().Add(time.Duration(hours) * time.Hour).Truncate(time.Hour)
+}
+
+func actorFor(user models.User) Actor {
+	return Actor{UserID: user.ID, Role: user.Role}
+}
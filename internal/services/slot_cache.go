@@ -0,0 +1,97 @@
+// internal/services/slot_cache.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// Cached slot listings live under a per-expert version. Invalidating bumps
+// the version instead of deleting the listing, so a listing loaded from the
+// database before a change committed is written under the old version and
+// never served afterwards. Old versions expire with slotCacheTTL.
+const slotCacheTTL = time.Hour
+
+func slotCacheVersionKey(expertID uint) string {
+	return fmt.Sprintf("available_slots_version:%d", expertID)
+}
+
+func slotCacheKey(expertID uint, version int64) string {
+	return fmt.Sprintf("available_slots:%d:%d", expertID, version)
+}
+
+// invalidateSlotCache drops the cached slot listings of the experts. Call
+// it once the transaction that changed their slots or bookings has
+// committed.
+func invalidateSlotCache(rdb *redis.Client, expertIDs ...uint) {
+	if len(expertIDs) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	pipe := rdb.Pipeline()
+	for _, expertID := range expertIDs {
+		pipe.Incr(ctx, slotCacheVersionKey(expertID))
+	}
+	pipe.Exec(ctx)
+}
+
+// invalidateSlotCacheFor drops the cached listing of the expert owning a
+// slot, deleted or not.
+func invalidateSlotCacheFor(db *gorm.DB, rdb *redis.Client, slotID uint) {
+	var slot models.AvailableSlot
+	if err := db.Unscoped().Select("expert_id").First(&slot, slotID).Error; err == nil {
+		invalidateSlotCache(rdb, slot.ExpertID)
+	}
+}
+
+// slotLoader collapses concurrent loads of the same slot listing into one,
+// so a cold or freshly invalidated cache sends a single query to the
+// database instead of one per waiting request.
+type slotLoader struct {
+	mu    sync.Mutex
+	calls map[string]*slotLoad
+}
+
+type slotLoad struct {
+	done  chan struct{}
+	slots []models.AvailableSlot
+	err   error
+}
+
+// do runs load once for all callers asking for key at the same time. Every
+// caller gets its own copy of the slots.
+func (l *slotLoader) do(key string, load func() ([]models.AvailableSlot, error)) ([]models.AvailableSlot, error) {
+	l.mu.Lock()
+	if l.calls == nil {
+		l.calls = make(map[string]*slotLoad)
+	}
+	call, ok := l.calls[key]
+	if !ok {
+		call = &slotLoad{done: make(chan struct{})}
+		l.calls[key] = call
+	}
+	l.mu.Unlock()
+
+	if ok {
+		<-call.done
+	} else {
+		call.slots, call.err = load()
+		close(call.done)
+
+		l.mu.Lock()
+		delete(l.calls, key)
+		l.mu.Unlock()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return append([]models.AvailableSlot(nil), call.slots...), nil
+}
@@ -0,0 +1,61 @@
+// internal/services/slot_cache_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBookedSlotLeavesCachedListing(t *testing.T) {
+	env := newTestEnv(t)
+	_, expert := env.createExpert(t)
+	client := env.createUser(t, "user")
+	booked := env.createSlot(t, expert.ID, futureHour(48), time.Hour)
+	free := env.createSlot(t, expert.ID, futureHour(50), time.Hour)
+
+	// Warm the cache
+	slots, err := env.experts.GetAvailableSlots(expert.ID)
+	if err != nil {
+		t.Fatalf("GetAvailableSlots: %v", err)
+	}
+	if !containsSlot(slots, booked.ID) || !containsSlot(slots, free.ID) {
+		t.Fatalf("listing %v is missing a slot", slotIDs(slots))
+	}
+
+	if _, err := env.bookings.CreateBooking(client.ID, CreateBookingRequest{
+		ExpertID:  expert.ID,
+		StartTime: booked.StartTime,
+		EndTime:   booked.EndTime,
+	}); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+
+	slots, err = env.experts.GetAvailableSlots(expert.ID)
+	if err != nil {
+		t.Fatalf("GetAvailableSlots: %v", err)
+	}
+	if containsSlot(slots, booked.ID) {
+		t.Fatalf("booked slot %d still listed in %v", booked.ID, slotIDs(slots))
+	}
+	if !containsSlot(slots, free.ID) {
+		t.Fatalf("free slot %d missing from %v", free.ID, slotIDs(slots))
+	}
+}
+
+func containsSlot(slots []models.AvailableSlot, id uint) bool {
+	for _, slot := range slots {
+		if slot.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func slotIDs(slots []models.AvailableSlot) []uint {
+	ids := make([]uint, len(slots))
+	for i, slot := range slots {
+		ids[i] = slot.ID
+	}
+	return ids
+}
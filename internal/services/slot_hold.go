@@ -0,0 +1,154 @@
+// internal/services/slot_hold.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrSlotHeld = errors.New("time slot is being held by another user")
+
+// SlotHold reserves a seat of a slot for a user while they finish booking
+// it. Holds live in Redis and lapse on their own when the TTL runs out.
+type SlotHold struct {
+	SlotID    uint      `json:"slot_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// acquireHoldScript takes a seat hold or extends the caller's own. The hash
+// maps each holder to the time their hold lapses; lapsed holds are pruned
+// and the hold is refused when other users already hold every free seat.
+var acquireHoldScript = redis.NewScript(`
+local now = tonumber(ARGV[3])
+local others = 0
+local holds = redis.call("HGETALL", KEYS[1])
+for i = 1, #holds, 2 do
+	if tonumber(holds[i + 1]) <= now then
+		redis.call("HDEL", KEYS[1], holds[i])
+	elseif holds[i] ~= ARGV[1] then
+		others = others + 1
+	end
+end
+if others >= tonumber(ARGV[4]) then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], now + tonumber(ARGV[2]))
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// HoldSlot holds a seat of a slot for the user for SlotHoldTTL. Holding it
+// again extends the hold. A user holds one slot at a time, so a new hold
+// drops the previous one.
+func (s *BookingService) HoldSlot(userID, slotID uint) (*SlotHold, error) {
+	var slot models.AvailableSlot
+	if err := s.db.First(&slot, slotID).Error; err != nil {
+		return nil, ErrSlotUnavailable
+	}
+	if slot.IsBooked {
+		return nil, ErrSlotTaken
+	}
+	if !slot.StartTime.After(time.Now()) {
+		return nil, ErrSlotUnavailable
+	}
+
+	ctx := context.Background()
+	holder := strconv.FormatUint(uint64(userID), 10)
+	ttl := s.policy.SlotHoldTTL
+	now := time.Now()
+
+	acquired, err := acquireHoldScript.Run(ctx, s.redis, []string{slotHoldKey(slotID)},
+		holder, ttl.Milliseconds(), now.UnixMilli(), slot.Capacity-slot.BookedCount).Int()
+	if err != nil {
+		return nil, err
+	}
+	if acquired == 0 {
+		return nil, ErrSlotHeld
+	}
+
+	userKey := userHoldKey(userID)
+	previous, err := s.redis.GetSet(ctx, userKey, slotID).Uint64()
+	if err == nil && uint(previous) != slotID {
+		s.ReleaseHold(userID, uint(previous))
+	}
+	s.redis.Expire(ctx, userKey, ttl)
+
+	return &SlotHold{
+		SlotID:    slotID,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// ReleaseHold gives up the user's hold on a slot. Holds of other users are
+// left alone.
+func (s *BookingService) ReleaseHold(userID, slotID uint) error {
+	holder := strconv.FormatUint(uint64(userID), 10)
+	return s.redis.HDel(context.Background(), slotHoldKey(slotID), holder).Err()
+}
+
+// seatsHeldByOthers counts the live holds other users have on a slot. If
+// Redis can't be reached the slot is treated as not held rather than
+// blocking bookings.
+func (s *BookingService) seatsHeldByOthers(slotID, userID uint) int {
+	holds, err := s.redis.HGetAll(context.Background(), slotHoldKey(slotID)).Result()
+	if err != nil {
+		return 0
+	}
+	delete(holds, strconv.FormatUint(uint64(userID), 10))
+	return countLiveHolds(holds, time.Now())
+}
+
+// applySlotHolds fills in the seats left on each slot after bookings and
+// checkout holds, dropping slots with none left from a listing.
+func applySlotHolds(ctx context.Context, rdb *redis.Client, slots []models.AvailableSlot) []models.AvailableSlot {
+	if len(slots) == 0 {
+		return slots
+	}
+
+	held := make([]int, len(slots))
+	pipe := rdb.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(slots))
+	for i, slot := range slots {
+		cmds[i] = pipe.HGetAll(ctx, slotHoldKey(slot.ID))
+	}
+	if _, err := pipe.Exec(ctx); err == nil {
+		now := time.Now()
+		for i, cmd := range cmds {
+			held[i] = countLiveHolds(cmd.Val(), now)
+		}
+	}
+
+	free := make([]models.AvailableSlot, 0, len(slots))
+	for i, slot := range slots {
+		slot.RemainingSeats = slot.Capacity - slot.BookedCount - held[i]
+		if slot.RemainingSeats > 0 {
+			free = append(free, slot)
+		}
+	}
+	return free
+}
+
+func countLiveHolds(holds map[string]string, now time.Time) int {
+	count := 0
+	for _, expires := range holds {
+		ms, err := strconv.ParseInt(expires, 10, 64)
+		if err == nil && ms > now.UnixMilli() {
+			count++
+		}
+	}
+	return count
+}
+
+func slotHoldKey(slotID uint) string {
+	return fmt.Sprintf("slot_holds:%d", slotID)
+}
+
+func userHoldKey(userID uint) string {
+	return fmt.Sprintf("slot_hold_user:%d", userID)
+}
@@ -0,0 +1,393 @@
+// internal/services/slot_management.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSlotEndBeforeStart  = errors.New("end time must be after start time")
+	ErrSlotInPast          = errors.New("slot must start in the future")
+	ErrSlotOverlap         = errors.New("time slot conflicts with existing slot")
+	ErrSlotHasBookings     = errors.New("slot has active bookings")
+	ErrGeneratedSlot       = errors.New("slot comes from an availability rule; change the rule or add an override instead")
+	ErrCapacityBelowBooked = errors.New("capacity can't be lower than the seats already taken")
+	ErrNoSlots             = errors.New("no slots given")
+	ErrTooManySlots        = errors.New("too many slots in one upload")
+)
+
+// Most slots accepted in one bulk upload
+const maxBulkSlots = 500
+
+// SlotRowError is a rejected slot of a bulk upload. Row is its 1-based
+// position in the upload, not counting a CSV header.
+type SlotRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// BulkSlotError lists every rejected slot of a bulk upload. Nothing is
+// created when any slot is rejected.
+type BulkSlotError struct {
+	Rows []SlotRowError
+}
+
+func (e *BulkSlotError) Error() string {
+	return fmt.Sprintf("%d of the slots are invalid", len(e.Rows))
+}
+
+// GetExpertSlots lists the expert's own slots starting from from, booked
+// or not. A zero to means no upper bound.
+func (s *ExpertService) GetExpertSlots(expertID uint, from, to time.Time) ([]models.AvailableSlot, error) {
+	query := s.db.Where("expert_id = ? AND start_time >= ?", expertID, from)
+	if !to.IsZero() {
+		query = query.Where("start_time < ?", to)
+	}
+
+	var slots []models.AvailableSlot
+	if err := query.Order("start_time").Find(&slots).Error; err != nil {
+		return nil, err
+	}
+	for i := range slots {
+		slots[i].RemainingSeats = slots[i].Capacity - slots[i].BookedCount
+	}
+	return slots, nil
+}
+
+// UpdateSlot changes the times or capacity of one of the expert's slots.
+// Times can only change while no seat is taken, and capacity can't drop
+// below the seats taken.
+func (s *ExpertService) UpdateSlot(expertID, slotID uint, req CreateSlotRequest) (*models.AvailableSlot, error) {
+	start, end := req.StartTime.UTC(), req.EndTime.UTC()
+	if err := validateSlotTimes(start, end, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var slot models.AvailableSlot
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND expert_id = ?", slotID, expertID).First(&slot).Error; err != nil {
+			return ErrSlotNotFound
+		}
+		if isGeneratedSlot(&slot) {
+			return ErrGeneratedSlot
+		}
+
+		capacity := seatCount(req.Capacity)
+		if capacity < slot.BookedCount {
+			return ErrCapacityBelowBooked
+		}
+
+		updates := map[string]interface{}{
+			"capacity":  capacity,
+			"is_booked": slot.BookedCount >= capacity,
+		}
+
+		if !start.Equal(slot.StartTime) || !end.Equal(slot.EndTime) {
+			if slot.BookedCount > 0 {
+				return ErrSlotHasBookings
+			}
+			overlaps, err := slotOverlaps(tx, expertID, slot.ID, start, end)
+			if err != nil {
+				return err
+			}
+			if overlaps {
+				return ErrSlotOverlap
+			}
+
+			// A moved slot is no longer part of the window it was carved from
+			updates["start_time"] = start
+			updates["end_time"] = end
+			updates["window_start"] = nil
+			updates["window_end"] = nil
+		}
+
+		if err := tx.Model(&slot).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&slot, slot.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slot.RemainingSeats = slot.Capacity - slot.BookedCount
+	invalidateSlotCache(s.redis, expertID)
+	return &slot, nil
+}
+
+// BulkCreateSlots creates several slots at once, such as a week of
+// availability. Either all of them are created or none, with every
+// rejected slot reported in a BulkSlotError.
+func (s *ExpertService) BulkCreateSlots(expertID uint, reqs []CreateSlotRequest) ([]models.AvailableSlot, error) {
+	if len(reqs) == 0 {
+		return nil, ErrNoSlots
+	}
+	if len(reqs) > maxBulkSlots {
+		return nil, ErrTooManySlots
+	}
+
+	var expert models.Expert
+	if err := s.db.First(&expert, expertID).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	slots := make([]models.AvailableSlot, len(reqs))
+	first, last := reqs[0].StartTime.UTC(), reqs[0].EndTime.UTC()
+	for i, req := range reqs {
+		slots[i] = models.AvailableSlot{
+			ExpertID:  expertID,
+			StartTime: req.StartTime.UTC(),
+			EndTime:   req.EndTime.UTC(),
+			Capacity:  seatCount(req.Capacity),
+		}
+		if slots[i].StartTime.Before(first) {
+			first = slots[i].StartTime
+		}
+		if slots[i].EndTime.After(last) {
+			last = slots[i].EndTime
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.AvailableSlot
+		if err := tx.Where("expert_id = ? AND start_time < ? AND end_time > ?", expertID, last, first).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+
+		var rejected []SlotRowError
+		for i := range slots {
+			if err := checkNewSlot(&slots[i], slots[:i], existing, now); err != nil {
+				rejected = append(rejected, SlotRowError{Row: i + 1, Error: err.Error()})
+			}
+		}
+		if len(rejected) > 0 {
+			return &BulkSlotError{Rows: rejected}
+		}
+
+		return tx.Create(&slots).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range slots {
+		slots[i].RemainingSeats = slots[i].Capacity
+	}
+	invalidateSlotCache(s.redis, expertID)
+	return slots, nil
+}
+
+// checkNewSlot validates a slot of a bulk upload against the slots before
+// it in the upload and the expert's existing slots.
+func checkNewSlot(slot *models.AvailableSlot, earlier, existing []models.AvailableSlot, now time.Time) error {
+	if err := validateSlotTimes(slot.StartTime, slot.EndTime, now); err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.StartTime.Before(slot.EndTime) && other.EndTime.After(slot.StartTime) {
+			return ErrSlotOverlap
+		}
+	}
+	for i, other := range earlier {
+		if other.StartTime.Before(slot.EndTime) && other.EndTime.After(slot.StartTime) {
+			return fmt.Errorf("overlaps slot %d of the upload", i+1)
+		}
+	}
+	return nil
+}
+
+// ParseSlotsCSV reads slots from CSV rows of start_time,end_time and an
+// optional capacity, with times in RFC 3339. A header row is skipped.
+func ParseSlotsCSV(r io.Reader) ([]CreateSlotRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && len(records[0]) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "start_time") {
+		records = records[1:]
+	}
+	if len(records) > maxBulkSlots {
+		return nil, ErrTooManySlots
+	}
+
+	reqs := make([]CreateSlotRequest, 0, len(records))
+	var rejected []SlotRowError
+	for i, record := range records {
+		req, err := parseSlotRecord(record)
+		if err != nil {
+			rejected = append(rejected, SlotRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	if len(rejected) > 0 {
+		return nil, &BulkSlotError{Rows: rejected}
+	}
+	return reqs, nil
+}
+
+func parseSlotRecord(record []string) (CreateSlotRequest, error) {
+	var req CreateSlotRequest
+	if len(record) < 2 || len(record) > 3 {
+		return req, errors.New("expected start_time,end_time[,capacity]")
+	}
+
+	var err error
+	if req.StartTime, err = time.Parse(time.RFC3339, strings.TrimSpace(record[0])); err != nil {
+		return req, errors.New("invalid start_time, expected RFC 3339")
+	}
+	if req.EndTime, err = time.Parse(time.RFC3339, strings.TrimSpace(record[1])); err != nil {
+		return req, errors.New("invalid end_time, expected RFC 3339")
+	}
+	if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+		if req.Capacity, err = strconv.Atoi(strings.TrimSpace(record[2])); err != nil || req.Capacity < 0 {
+			return req, errors.New("invalid capacity")
+		}
+	}
+	return req, nil
+}
+
+// DeleteSlot removes one of the expert's slots. A slot with active
+// bookings or a pending reschedule into it is refused unless
+// cancelBookings is set; then the bookings are cancelled on the expert's
+// behalf, the reschedules declined and the clients notified through the
+// usual hooks. Pending waitlist offers for the slot lapse.
+func (s *BookingService) DeleteSlot(expertID, slotID uint, actor Actor, cancelBookings bool, reason string) ([]models.Booking, error) {
+	var cancelled []models.Booking
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var slot models.AvailableSlot
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND expert_id = ?", slotID, expertID).First(&slot).Error; err != nil {
+			return ErrSlotNotFound
+		}
+		if isGeneratedSlot(&slot) {
+			return ErrGeneratedSlot
+		}
+
+		var bookings []models.Booking
+		if err := tx.Where("slot_id = ? AND status IN ?", slot.ID, activeBookingStatuses).
+			Order("start_time").Find(&bookings).Error; err != nil {
+			return err
+		}
+		var incoming int64
+		if err := tx.Model(&models.BookingReschedule{}).
+			Where("new_slot_id = ? AND status = ?", slot.ID, models.RescheduleStatusPending).
+			Count(&incoming).Error; err != nil {
+			return err
+		}
+		if (len(bookings) > 0 || incoming > 0) && !cancelBookings {
+			return ErrSlotHasBookings
+		}
+
+		// Deleted first so the seats freed below aren't offered to the waitlist
+		if err := tx.Delete(&slot).Error; err != nil {
+			return err
+		}
+
+		for _, b := range bookings {
+			booking, err := s.cancelBooking(tx, b.ID, actor, reason, false)
+			if err != nil {
+				return err
+			}
+			cancelled = append(cancelled, *booking)
+		}
+
+		// Reschedules of the cancelled bookings were closed with them
+		var reschedules []models.BookingReschedule
+		if err := tx.Where("new_slot_id = ? AND status = ?", slot.ID, models.RescheduleStatusPending).
+			Find(&reschedules).Error; err != nil {
+			return err
+		}
+		for i := range reschedules {
+			booking, err := lockBooking(tx, reschedules[i].BookingID)
+			if err != nil {
+				return err
+			}
+			reschedules[i].Booking = booking
+			if err := decideRescheduleStatus(tx, &reschedules[i], models.RescheduleStatusDeclined, actor); err != nil {
+				return err
+			}
+			if err := s.runRescheduleHooks(tx, RescheduleDeclined, &reschedules[i], actor, bookingRole(booking, actor)); err != nil {
+				return err
+			}
+		}
+
+		return closeSlotWaitlist(tx, slot.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.redis.Del(context.Background(), slotHoldKey(slotID))
+	invalidateSlotCache(s.redis, expertID)
+	return cancelled, nil
+}
+
+// closeSlotWaitlist lapses the pending offers of a deleted slot, putting
+// their users back in line, and cancels waitlist entries for that slot.
+func closeSlotWaitlist(tx *gorm.DB, slotID uint) error {
+	offered := tx.Model(&models.WaitlistOffer{}).Select("entry_id").
+		Where("slot_id = ? AND status = ?", slotID, models.OfferStatusPending)
+	if err := tx.Model(&models.WaitlistEntry{}).
+		Where("id IN (?) AND status = ?", offered, models.WaitlistStatusOffered).
+		Update("status", models.WaitlistStatusWaiting).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.WaitlistOffer{}).
+		Where("slot_id = ? AND status = ?", slotID, models.OfferStatusPending).
+		Update("status", models.OfferStatusExpired).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.WaitlistEntry{}).
+		Where("slot_id = ? AND status IN ?", slotID, []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+		Update("status", models.WaitlistStatusCancelled).Error
+}
+
+// validateSlotTimes checks that a slot ends after it starts and starts
+// after now.
+func validateSlotTimes(start, end, now time.Time) error {
+	if !end.After(start) {
+		return ErrSlotEndBeforeStart
+	}
+	if !start.After(now) {
+		return ErrSlotInPast
+	}
+	return nil
+}
+
+// slotOverlaps reports whether start to end overlaps any of the expert's
+// slots other than excludeID.
+func slotOverlaps(tx *gorm.DB, expertID, excludeID uint, start, end time.Time) (bool, error) {
+	var count int64
+	err := tx.Model(&models.AvailableSlot{}).
+		Where("expert_id = ? AND id <> ? AND start_time < ? AND end_time > ?", expertID, excludeID, end, start).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// isGeneratedSlot reports whether a slot was materialized from an
+// availability rule or override. Changes to it would be undone the next
+// time availability is materialized.
+func isGeneratedSlot(slot *models.AvailableSlot) bool {
+	return slot.RuleID != nil || slot.OverrideID != nil
+}
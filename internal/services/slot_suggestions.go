@@ -0,0 +1,153 @@
+// internal/services/slot_suggestions.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultSuggestions = 3
+	MaxSuggestions     = 20
+
+	// How far ahead similar experts are looked for
+	similarExpertsWindow = 7 * 24 * time.Hour
+)
+
+// TimeConflict reports whether a booking was refused for its time, so
+// other times could succeed.
+func TimeConflict(err error) bool {
+	for _, target := range []error{
+		ErrSlotUnavailable, ErrSlotTaken, ErrSlotHeld, ErrExpertConflict, ErrUserConflict,
+		ErrBookingNoticeTooShort, ErrBookingTooFarAhead, ErrBufferConflict, ErrDailyLimitReached,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextAvailable returns the first n slots of the expert a client can book
+// starting after after. With a session type only times a session of that
+// type fits are returned.
+func (s *ExpertService) NextAvailable(expertID uint, sessionTypeID *uint, after time.Time, n int) ([]models.AvailableSlot, error) {
+	slots, err := s.sessionSlots(expertID, sessionTypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := []models.AvailableSlot{}
+	for _, slot := range slots {
+		if len(next) == n {
+			break
+		}
+		if !slot.StartTime.Before(after) {
+			next = append(next, slot)
+		}
+	}
+	return next, nil
+}
+
+// SuggestSlots returns the n bookable slots of the expert starting nearest
+// to near, before or after it, in time order. With a session type only
+// times a session of that type fits are suggested.
+func (s *ExpertService) SuggestSlots(expertID uint, sessionTypeID *uint, near time.Time, n int) ([]models.AvailableSlot, error) {
+	slots, err := s.sessionSlots(expertID, sessionTypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	distance := func(t time.Time) time.Duration {
+		if d := t.Sub(near); d > 0 {
+			return d
+		}
+		return near.Sub(t)
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return distance(slots[i].StartTime) < distance(slots[j].StartTime)
+	})
+	if len(slots) > n {
+		slots = slots[:n]
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].StartTime.Before(slots[j].StartTime)
+	})
+	return slots, nil
+}
+
+// sessionSlots returns the expert's bookable slots, or with a session type
+// the slots a session of that type can start in.
+func (s *ExpertService) sessionSlots(expertID uint, sessionTypeID *uint) ([]models.AvailableSlot, error) {
+	var length time.Duration
+	if sessionTypeID != nil {
+		sessionType, err := findSessionType(s.db, expertID, *sessionTypeID)
+		if err != nil {
+			return nil, err
+		}
+		length = time.Duration(sessionType.DurationMinutes) * time.Minute
+	}
+
+	slots, err := s.GetAvailableSlots(expertID)
+	if err != nil || length == 0 {
+		return slots, err
+	}
+	return slotsFitting(slots, length), nil
+}
+
+// slotsFitting returns the slots a session of the given length fits in,
+// either alone or joined with the free slots following it the way
+// joinFreeSlots joins them when the session is booked. A joined slot ends
+// where the last slot it needs ends. slots must be in time order.
+func slotsFitting(slots []models.AvailableSlot, length time.Duration) []models.AvailableSlot {
+	fitting := []models.AvailableSlot{}
+	for i := range slots {
+		slot := slots[i]
+		for j := i + 1; j < len(slots) && slot.EndTime.Sub(slot.StartTime) < length; j++ {
+			prev, next := &slots[j-1], &slots[j]
+			if !freePiece(prev) || !freePiece(next) || !next.StartTime.Equal(slot.EndTime) || !joinable(prev, next) {
+				break
+			}
+			slot.EndTime = next.EndTime
+		}
+		if slot.EndTime.Sub(slot.StartTime) >= length {
+			fitting = append(fitting, slot)
+		}
+	}
+	return fitting
+}
+
+// SimilarExperts returns up to n other experts of the same speciality who
+// are free in the week from near, earliest first.
+func (s *ExpertService) SimilarExperts(expertID uint, near time.Time, n int) ([]ExpertAvailability, error) {
+	var expert models.Expert
+	if err := s.db.Select("id", "speciality").First(&expert, expertID).Error; err != nil {
+		return nil, ErrExpertNotFound
+	}
+	if expert.Speciality == "" {
+		return []ExpertAvailability{}, nil
+	}
+
+	if now := time.Now(); near.Before(now) {
+		near = now
+	}
+	results, err := s.SearchAvailability(SlotSearchRequest{
+		From:       near,
+		To:         near.Add(similarExpertsWindow),
+		Speciality: expert.Speciality,
+		Limit:      n + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	similar := []ExpertAvailability{}
+	for _, result := range results {
+		if result.Expert.ID != expertID && len(similar) < n {
+			similar = append(similar, result)
+		}
+	}
+	return similar, nil
+}
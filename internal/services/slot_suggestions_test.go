@@ -0,0 +1,79 @@
+// internal/services/slot_suggestions_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSuggestSlotsJoinsRuleSessions(t *testing.T) {
+	env := newTestEnv(t)
+	_, expert := env.createExpert(t)
+	client := env.createUser(t, "user")
+
+	if err := env.db.Create(&models.AvailabilityRule{
+		ExpertID:       expert.ID,
+		Weekdays:       "MO,TU,WE,TH,FR,SA,SU",
+		StartTime:      "09:00",
+		EndTime:        "12:00",
+		SessionMinutes: 30,
+		Capacity:       1,
+	}).Error; err != nil {
+		t.Fatalf("creating rule: %v", err)
+	}
+	if err := materializeAvailability(env.db, expert.ID, time.Now()); err != nil {
+		t.Fatalf("materializing: %v", err)
+	}
+	sessionType := models.SessionType{ExpertID: expert.ID, Name: "Consultation", DurationMinutes: 60, IsActive: true}
+	if err := env.db.Create(&sessionType).Error; err != nil {
+		t.Fatalf("creating session type: %v", err)
+	}
+
+	day := time.Now().UTC().AddDate(0, 0, 3)
+	morning := time.Date(day.Year(), day.Month(), day.Day(), 9, 0, 0, 0, time.UTC)
+
+	// Taking 10:30 leaves 10:00 without a free half hour after it
+	if _, err := env.bookings.CreateBooking(client.ID, CreateBookingRequest{
+		ExpertID:  expert.ID,
+		StartTime: morning.Add(90 * time.Minute),
+		EndTime:   morning.Add(2 * time.Hour),
+	}); err != nil {
+		t.Fatalf("booking 10:30: %v", err)
+	}
+
+	suggestions, err := env.experts.SuggestSlots(expert.ID, &sessionType.ID, morning, 4)
+	if err != nil {
+		t.Fatalf("SuggestSlots: %v", err)
+	}
+	if len(suggestions) == 0 {
+		t.Fatal("no suggestions for an hour long session on 30 minute rule slots")
+	}
+	for _, slot := range suggestions {
+		if slot.EndTime.Sub(slot.StartTime) < time.Hour {
+			t.Errorf("suggested %v-%v is shorter than the session", slot.StartTime, slot.EndTime)
+		}
+		if offset := slot.StartTime.Sub(morning); offset == time.Hour || offset == 150*time.Minute {
+			t.Errorf("suggested %v, where an hour doesn't fit", slot.StartTime)
+		}
+	}
+
+	next, err := env.experts.NextAvailable(expert.ID, &sessionType.ID, morning, 1)
+	if err != nil {
+		t.Fatalf("NextAvailable: %v", err)
+	}
+	if len(next) != 1 || !next[0].StartTime.Equal(morning) {
+		t.Fatalf("next available %v, want the session at %v", slotIDs(next), morning)
+	}
+
+	// A suggestion can be booked as suggested
+	first := suggestions[0]
+	if _, err := env.bookings.CreateBooking(client.ID, CreateBookingRequest{
+		ExpertID:      expert.ID,
+		SessionTypeID: &sessionType.ID,
+		StartTime:     first.StartTime,
+		EndTime:       first.StartTime.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("booking suggestion at %v: %v", first.StartTime, err)
+	}
+}
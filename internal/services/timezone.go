@@ -0,0 +1,73 @@
+// internal/services/timezone.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"time"
+)
+
+// Layout used when showing times to people, e.g. in emails
+const displayTimeLayout = "Mon, 02 Jan 2006 15:04 MST (-07:00)"
+
+var ErrInvalidTimezone = errors.New("invalid time zone")
+
+// LoadTimezone resolves an IANA zone name. An empty name means UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// locationOrUTC is LoadTimezone for stored values, falling back to UTC.
+func locationOrUTC(name string) *time.Location {
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FormatInZone renders t in the given zone with its abbreviation and
+// offset so the reader can't mistake whose local time it is.
+func FormatInZone(t time.Time, timezone string) string {
+	return t.In(locationOrUTC(timezone)).Format(displayTimeLayout)
+}
+
+// BookingsInZone converts the times of the bookings to loc for responses.
+func BookingsInZone(bookings []models.Booking, loc *time.Location) {
+	for i := range bookings {
+		BookingInZone(&bookings[i], loc)
+	}
+}
+
+func BookingInZone(booking *models.Booking, loc *time.Location) {
+	booking.StartTime = booking.StartTime.In(loc)
+	booking.EndTime = booking.EndTime.In(loc)
+}
+
+// RescheduleInZone converts the times of a reschedule and its booking to
+// loc for responses.
+func RescheduleInZone(reschedule *models.BookingReschedule, loc *time.Location) {
+	reschedule.OldStartTime = reschedule.OldStartTime.In(loc)
+	reschedule.OldEndTime = reschedule.OldEndTime.In(loc)
+	reschedule.NewStartTime = reschedule.NewStartTime.In(loc)
+	reschedule.NewEndTime = reschedule.NewEndTime.In(loc)
+	if reschedule.Booking != nil {
+		BookingInZone(reschedule.Booking, loc)
+	}
+}
+
+// SlotsInZone converts the times of the slots to loc for responses.
+func SlotsInZone(slots []models.AvailableSlot, loc *time.Location) {
+	for i := range slots {
+		slots[i].StartTime = slots[i].StartTime.In(loc)
+		slots[i].EndTime = slots[i].EndTime.In(loc)
+	}
+}
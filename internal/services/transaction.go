@@ -0,0 +1,44 @@
+// internal/services/transaction.go
+package services
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Work that must not happen before a transaction commits, such as sending
+// email, is queued with afterCommit by code running inside it (often a
+// hook) and run by inTransaction once the transaction has committed.
+
+type commitQueueKey struct{}
+
+type commitQueue struct {
+	funcs []func()
+}
+
+// inTransaction runs fn in a transaction, then the work fn queued with
+// afterCommit if the transaction committed.
+func inTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	queue := &commitQueue{}
+	ctx := context.WithValue(db.Statement.Context, commitQueueKey{}, queue)
+	if err := db.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+
+	for _, f := range queue.funcs {
+		f()
+	}
+	return nil
+}
+
+// afterCommit queues f to run once the transaction of tx has committed and
+// drops it on rollback. Outside inTransaction f runs straight away.
+func afterCommit(tx *gorm.DB, f func()) {
+	queue, ok := tx.Statement.Context.Value(commitQueueKey{}).(*commitQueue)
+	if !ok {
+		f()
+		return
+	}
+	queue.funcs = append(queue.funcs, f)
+}
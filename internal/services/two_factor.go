@@ -0,0 +1,312 @@
+// internal/services/two_factor.go
+package services
+
+import (
+	"consultation-booking/internal/auth"
+	"consultation-booking/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer           = "Consultation Booking"
+	totpEnrollmentTTL    = 10 * time.Minute
+	recoveryCodeCount    = 10
+	maxChallengeAttempts = 5
+	challengeAttemptsTTL = 10 * time.Minute
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrNoPendingEnrollment     = errors.New("no pending two-factor enrollment, start again")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+	ErrInvalidRole             = errors.New("invalid role")
+)
+
+// TwoFactorChallenge is returned by Login instead of tokens when the account
+// has two-factor authentication enabled.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorActivation is returned once when enrollment completes: fresh
+// tokens carrying the second factor and the recovery codes in clear.
+type TwoFactorActivation struct {
+	RecoveryCodes []string      `json:"recovery_codes"`
+	Auth          *AuthResponse `json:"auth"`
+}
+
+// EnrollTwoFactor creates a pending TOTP secret. It only becomes active
+// once VerifyTwoFactor confirms the user's app produces matching codes.
+func (s *UserService) EnrollTwoFactor(userID uint) (*TwoFactorEnrollment, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redis.Set(context.Background(), totpEnrollmentKey(userID), secret, totpEnrollmentTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// VerifyTwoFactor completes enrollment with a code from the authenticator
+// app, enables two-factor authentication and issues recovery codes.
+func (s *UserService) VerifyTwoFactor(userID uint, req TwoFactorCodeRequest) (*TwoFactorActivation, error) {
+	ctx := context.Background()
+	secret, err := s.redis.Get(ctx, totpEnrollmentKey(userID)).Result()
+	if err != nil {
+		return nil, ErrNoPendingEnrollment
+	}
+
+	if !s.checkTOTP(userID, secret, req.Code) {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var user models.User
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled": true,
+			"totp_secret":        secret,
+		}).Error; err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.redis.Del(ctx, totpEnrollmentKey(userID))
+
+	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorActivation{
+		RecoveryCodes: codes,
+		Auth: &AuthResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			User:         user,
+		},
+	}, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a
+// current code, and removes the recovery codes.
+func (s *UserService) DisableTwoFactor(userID uint, req TwoFactorCodeRequest) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if !s.checkTOTP(userID, user.TOTPSecret, req.Code) {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"totp_secret":        "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a
+// current code.
+func (s *UserService) RegenerateRecoveryCodes(userID uint, req TwoFactorCodeRequest) ([]string, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if !s.checkTOTP(userID, user.TOTPSecret, req.Code) {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// LoginTwoFactor completes a login challenge with a TOTP code or a recovery
+// code and issues tokens marked as second-factor verified.
+func (s *UserService) LoginTwoFactor(req TwoFactorLoginRequest) (*AuthResponse, error) {
+	claims, err := s.tokens.Parse(req.ChallengeToken, auth.TokenTypeChallenge)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	// Bound guessing within a single challenge
+	ctx := context.Background()
+	attemptsKey := "2fa_challenge_attempts:" + claims.ID
+	attempts, err := s.redis.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if attempts == 1 {
+		s.redis.Expire(ctx, attemptsKey, challengeAttemptsTTL)
+	}
+	if attempts > maxChallengeAttempts {
+		return nil, ErrInvalidChallenge
+	}
+
+	var user models.User
+	if err := s.db.First(&user, claims.UserID).Error; err != nil || !user.IsActive || !user.TwoFactorEnabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	switch {
+	case req.Code != "":
+		if !s.checkTOTP(user.ID, user.TOTPSecret, req.Code) {
+			return nil, ErrInvalidTwoFactorCode
+		}
+	case req.RecoveryCode != "":
+		if !s.useRecoveryCode(user.ID, req.RecoveryCode) {
+			return nil, ErrInvalidTwoFactorCode
+		}
+	default:
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	s.redis.Del(ctx, attemptsKey)
+
+	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
+
+// GetMFARequiredRoles lists the roles that must use two-factor
+// authentication on role-restricted routes.
+func (s *UserService) GetMFARequiredRoles() ([]string, error) {
+	return s.redis.SMembers(context.Background(), auth.MFARequiredRolesKey).Result()
+}
+
+// SetMFARequiredRoles replaces the roles that must use two-factor
+// authentication. Enforced by middleware.RoleMiddleware.
+func (s *UserService) SetMFARequiredRoles(roles []string) error {
+	members := make([]interface{}, 0, len(roles))
+	for _, role := range roles {
+		if role != "user" && role != "expert" && role != "admin" {
+			return ErrInvalidRole
+		}
+		members = append(members, role)
+	}
+
+	ctx := context.Background()
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, auth.MFARequiredRolesKey)
+	if len(members) > 0 {
+		pipe.SAdd(ctx, auth.MFARequiredRolesKey, members...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// checkTOTP validates a code and refuses to accept the same time step
+// twice, so an observed code can't be replayed.
+func (s *UserService) checkTOTP(userID uint, secret, code string) bool {
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	key := fmt.Sprintf("totp_used:%d:%d", userID, step)
+	fresh, err := s.redis.SetNX(context.Background(), key, 1, 2*time.Minute).Result()
+	return err == nil && fresh
+}
+
+func (s *UserService) useRecoveryCode(userID uint, code string) bool {
+	hash := hashToken(normalizeRecoveryCode(code))
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := auth.NewTokenID()[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func totpEnrollmentKey(userID uint) string {
+	return fmt.Sprintf("totp_enroll:%d", userID)
+}
@@ -2,20 +2,52 @@
 package services
 
 import (
+	"consultation-booking/internal/auth"
 	"consultation-booking/internal/models"
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, all sessions of this login were revoked")
+	ErrAccountDeactivated  = errors.New("account is deactivated")
+	ErrUserExists          = errors.New("user already exists")
+)
+
+// Results of rotateRefreshScript
+const (
+	rotateReused        = 0
+	rotateOK            = 1
+	rotateUnknownFamily = -1
+)
+
+// rotateRefreshScript swaps the family's current refresh token ID for a new
+// one if the presented ID is the current one. A stale ID revokes the family.
+var rotateRefreshScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "EX", ARGV[3])
+return 1
+`)
+
 type UserService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db           *gorm.DB
+	redis        *redis.Client
+	tokens       *auth.TokenManager
+	emailService *EmailService
 }
 
 type LoginRequest struct {
@@ -28,6 +60,23 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 	Name     string `json:"name" binding:"required"`
 	Phone    string `json:"phone"`
+	Timezone string `json:"timezone"`
+}
+
+// UpdateProfileRequest lists the profile fields users may change
+// themselves. Omitted fields are left as they are.
+type UpdateProfileRequest struct {
+	Name        *string `json:"name"`
+	Phone       *string `json:"phone"`
+	Avatar      *string `json:"avatar"`
+	Description *string `json:"description"`
+	Gender      *string `json:"gender"`
+	Timezone    *string `json:"timezone"`
+	Email       *string `json:"email" binding:"omitempty,email"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type AuthResponse struct {
@@ -36,10 +85,12 @@ type AuthResponse struct {
 	User         models.User `json:"user"`
 }
 
-func NewUserService(db *gorm.DB, redis *redis.Client) *UserService {
+func NewUserService(db *gorm.DB, redis *redis.Client, tokens *auth.TokenManager, emailService *EmailService) *UserService {
 	return &UserService{
-		db:    db,
-		redis: redis,
+		db:           db,
+		redis:        redis,
+		tokens:       tokens,
+		emailService: emailService,
 	}
 }
 
@@ -47,7 +98,14 @@ func (s *UserService) Register(req RegisterRequest) (*AuthResponse, error) {
 	// Check if user already exists
 	var existingUser models.User
 	if err := s.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		return nil, errors.New("user already exists")
+		return nil, ErrUserExists
+	}
+
+	if _, err := LoadTimezone(req.Timezone); err != nil {
+		return nil, err
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
 	}
 
 	// Hash password
@@ -62,6 +120,7 @@ func (s *UserService) Register(req RegisterRequest) (*AuthResponse, error) {
 		Password: string(hashedPassword),
 		Name:     req.Name,
 		Phone:    req.Phone,
+		Timezone: req.Timezone,
 		Role:     "user",
 	}
 
@@ -69,8 +128,10 @@ func (s *UserService) Register(req RegisterRequest) (*AuthResponse, error) {
 		return nil, err
 	}
 
+	go s.sendVerificationEmail(user)
+
 	// Generate tokens
-	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role)
+	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role, false)
 	if err != nil {
 		return nil, err
 	}
@@ -82,28 +143,43 @@ func (s *UserService) Register(req RegisterRequest) (*AuthResponse, error) {
 	}, nil
 }
 
-func (s *UserService) Login(req LoginRequest) (*AuthResponse, error) {
+// Login checks the password. Accounts with two-factor authentication get a
+// short-lived challenge instead of tokens, to be completed with
+// LoginTwoFactor.
+func (s *UserService) Login(req LoginRequest) (*AuthResponse, *TwoFactorChallenge, error) {
 	var user models.User
 	if err := s.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, nil, errors.New("invalid credentials")
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	if !user.IsActive {
+		return nil, nil, ErrAccountDeactivated
+	}
+
+	if user.TwoFactorEnabled {
+		challenge, err := s.tokens.Sign(auth.Claims{UserID: user.ID, Type: auth.TokenTypeChallenge})
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &TwoFactorChallenge{TwoFactorRequired: true, ChallengeToken: challenge}, nil
 	}
 
 	// Generate tokens
-	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role)
+	accessToken, refreshToken, err := s.generateTokens(user.ID, user.Role, false)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	return &AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		User:         user,
-	}, nil
+	}, nil, nil
 }
 
 func (s *UserService) GetProfile(userID uint) (*models.User, error) {
@@ -114,8 +190,63 @@ func (s *UserService) GetProfile(userID uint) (*models.User, error) {
 	return &user, nil
 }
 
-func (s *UserService) UpdateProfile(userID uint, updates map[string]interface{}) error {
-	return s.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+func (s *UserService) UpdateProfile(userID uint, req UpdateProfileRequest) error {
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Phone != nil {
+		updates["phone"] = *req.Phone
+	}
+	if req.Avatar != nil {
+		updates["avatar"] = *req.Avatar
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Gender != nil {
+		updates["gender"] = *req.Gender
+	}
+	if req.Timezone != nil {
+		if _, err := LoadTimezone(*req.Timezone); err != nil || *req.Timezone == "" {
+			return ErrInvalidTimezone
+		}
+		updates["timezone"] = *req.Timezone
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "email", "name").First(&user, userID).Error; err != nil {
+		return err
+	}
+	emailChanged := req.Email != nil && *req.Email != user.Email
+	if emailChanged {
+		var existing models.User
+		if err := s.db.Where("email = ? AND id <> ?", *req.Email, userID).First(&existing).Error; err == nil {
+			return ErrUserExists
+		}
+
+		// A new address has to be verified again
+		updates["email"] = *req.Email
+		updates["email_verified"] = false
+		updates["email_verified_at"] = nil
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	if emailChanged {
+		// Links sent to the old address must not verify the new one
+		if err := revokeOneTimeToken(context.Background(), s.redis, emailVerificationPurpose, userID); err != nil {
+			return err
+		}
+		user.Email = *req.Email
+		go s.sendVerificationEmail(user)
+	}
+	return nil
 }
 
 func (s *UserService) GetBookingHistory(userID uint) ([]models.Booking, error) {
@@ -128,32 +259,176 @@ func (s *UserService) GetBookingHistory(userID uint) ([]models.Booking, error) {
 	return bookings, err
 }
 
-func (s *UserService) generateTokens(userID uint, role string) (string, string, error) {
-	// Access token (1 hour)
-	accessClaims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour).Unix(),
+// Refresh token families: every login starts a family, and each refresh
+// rotates the family's single valid refresh token. Presenting an older
+// token from the family means it was stolen, so the family is revoked.
+func (s *UserService) RefreshToken(refreshToken string) (*AuthResponse, error) {
+	claims, err := s.tokens.Parse(refreshToken, auth.TokenTypeRefresh)
+	if err != nil || claims.FamilyID == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Tokens issued before a logout-all are dead
+	ctx := context.Background()
+	currentVersion, err := auth.TokenVersion(ctx, s.redis, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Version < currentVersion {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newJTI := auth.NewTokenID()
+	result, err := rotateRefreshScript.Run(ctx, s.redis,
+		[]string{refreshFamilyKey(claims.FamilyID)}, claims.ID, newJTI, int(s.tokens.RefreshTTL().Seconds()),
+	).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	switch result {
+	case rotateUnknownFamily:
+		return nil, ErrInvalidRefreshToken
+	case rotateReused:
+		s.redis.SRem(ctx, userFamiliesKey(claims.UserID), claims.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	var user models.User
+	if err := s.db.First(&user, claims.UserID).Error; err != nil || !user.IsActive {
+		s.revokeFamily(claims.UserID, claims.FamilyID)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	accessToken, newRefreshToken, err := s.signTokens(user.ID, user.Role, claims.FamilyID, newJTI, currentVersion, claims.MFA)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		User:         user,
+	}, nil
+}
+
+// generateTokens starts a new refresh token family for a fresh login. mfa
+// records whether a second factor was verified.
+func (s *UserService) generateTokens(userID uint, role string, mfa bool) (string, string, error) {
+	familyID := auth.NewTokenID()
+	refreshJTI := auth.NewTokenID()
+
+	ctx := context.Background()
+	version, err := auth.TokenVersion(ctx, s.redis, userID)
+	if err != nil {
+		return "", "", err
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString([]byte("your-secret-key"))
+
+	accessString, refreshString, err := s.signTokens(userID, role, familyID, refreshJTI, version, mfa)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Refresh token (7 days)
-	refreshClaims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
+	// Store the family's current refresh token in Redis
+	if err := s.redis.Set(ctx, refreshFamilyKey(familyID), refreshJTI, s.tokens.RefreshTTL()).Err(); err != nil {
+		return "", "", err
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString([]byte("your-secret-key"))
+	s.redis.SAdd(ctx, userFamiliesKey(userID), familyID)
+	s.redis.Expire(ctx, userFamiliesKey(userID), s.tokens.RefreshTTL())
+
+	return accessString, refreshString, nil
+}
+
+func (s *UserService) signTokens(userID uint, role, familyID, refreshJTI string, version int64, mfa bool) (string, string, error) {
+	accessString, err := s.tokens.Sign(auth.Claims{
+		UserID:   userID,
+		Role:     role,
+		Type:     auth.TokenTypeAccess,
+		FamilyID: familyID,
+		Version:  version,
+		MFA:      mfa,
+	})
 	if err != nil {
 		return "", "", err
 	}
 
-	// Store refresh token in Redis
-	s.redis.Set(context.Background(), "refresh_token:"+string(rune(userID)), refreshString, time.Hour*24*7)
+	refreshClaims := auth.Claims{
+		UserID:   userID,
+		Type:     auth.TokenTypeRefresh,
+		FamilyID: familyID,
+		Version:  version,
+		MFA:      mfa,
+	}
+	refreshClaims.ID = refreshJTI
+	refreshString, err := s.tokens.Sign(refreshClaims)
+	if err != nil {
+		return "", "", err
+	}
 
 	return accessString, refreshString, nil
 }
+
+// Logout ends the current session: the access token is denylisted until it
+// expires and its refresh token family is revoked.
+func (s *UserService) Logout(userID uint, jti, familyID string, expiresAt time.Time) error {
+	if ttl := time.Until(expiresAt); jti != "" && ttl > 0 {
+		if err := s.redis.Set(context.Background(), auth.RevokedTokenKey(jti), 1, ttl).Err(); err != nil {
+			return err
+		}
+	}
+
+	if familyID != "" {
+		s.revokeFamily(userID, familyID)
+	}
+	return nil
+}
+
+// LogoutAll ends every session of the user by bumping their token version,
+// which invalidates all tokens issued so far, and revoking all refresh
+// token families.
+func (s *UserService) LogoutAll(userID uint) error {
+	ctx := context.Background()
+	if err := s.redis.Incr(ctx, auth.TokenVersionKey(userID)).Err(); err != nil {
+		return err
+	}
+
+	families, err := s.redis.SMembers(ctx, userFamiliesKey(userID)).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	for _, familyID := range families {
+		s.redis.Del(ctx, refreshFamilyKey(familyID))
+	}
+	return s.redis.Del(ctx, userFamiliesKey(userID)).Err()
+}
+
+// SetActive activates or deactivates an account. Deactivation cuts off all
+// existing sessions immediately.
+func (s *UserService) SetActive(userID uint, active bool) error {
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Update("is_active", active)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	if active {
+		return nil
+	}
+	return s.LogoutAll(userID)
+}
+
+func (s *UserService) revokeFamily(userID uint, familyID string) {
+	ctx := context.Background()
+	s.redis.Del(ctx, refreshFamilyKey(familyID))
+	s.redis.SRem(ctx, userFamiliesKey(userID), familyID)
+}
+
+func refreshFamilyKey(familyID string) string {
+	return "refresh_family:" + familyID
+}
+
+func userFamiliesKey(userID uint) string {
+	return fmt.Sprintf("refresh_families:%d", userID)
+}
@@ -0,0 +1,31 @@
+// internal/services/user_service_test.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"testing"
+)
+
+func TestUpdateProfileRejectsTakenEmail(t *testing.T) {
+	env := newTestEnv(t)
+	users := NewUserService(env.db, env.redis, nil, nil)
+	user := env.createUser(t, "user")
+	other := env.createUser(t, "user")
+
+	err := users.UpdateProfile(user.ID, UpdateProfileRequest{Email: &other.Email})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("taking another user's email: got %v, want ErrUserExists", err)
+	}
+
+	var stored models.User
+	env.db.First(&stored, user.ID)
+	if stored.Email != user.Email {
+		t.Fatalf("email changed to %q", stored.Email)
+	}
+
+	// Resubmitting your own address is not a conflict
+	if err := users.UpdateProfile(user.ID, UpdateProfileRequest{Email: &user.Email}); err != nil {
+		t.Fatalf("keeping own email: %v", err)
+	}
+}
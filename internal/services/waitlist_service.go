@@ -0,0 +1,404 @@
+// internal/services/waitlist_service.go
+package services
+
+import (
+	"consultation-booking/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyWaitlisted     = errors.New("you are already on the waitlist for this expert")
+	ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+	ErrOfferNotFound         = errors.New("waitlist offer not found")
+	ErrOfferExpired          = errors.New("waitlist offer has expired")
+)
+
+type WaitlistService struct {
+	db           *gorm.DB
+	redis        *redis.Client
+	emailService *EmailService
+	bookings     *BookingService
+	offerTTL     time.Duration
+}
+
+type JoinWaitlistRequest struct {
+	ExpertID uint       `json:"expert_id" binding:"required"`
+	SlotID   *uint      `json:"slot_id"`
+	From     *time.Time `json:"from"`
+	Until    *time.Time `json:"until"`
+}
+
+type ClaimOfferRequest struct {
+	Notes  string `json:"notes"`
+	Format string `json:"format"` // online, offline
+}
+
+func NewWaitlistService(db *gorm.DB, redis *redis.Client, emailService *EmailService, bookings *BookingService, offerTTL time.Duration) *WaitlistService {
+	return &WaitlistService{
+		db:           db,
+		redis:        redis,
+		emailService: emailService,
+		bookings:     bookings,
+		offerTTL:     offerTTL,
+	}
+}
+
+// JoinWaitlist puts the user in line for the expert's freed slots.
+func (s *WaitlistService) JoinWaitlist(userID uint, req JoinWaitlistRequest) (*models.WaitlistEntry, error) {
+	var expert models.Expert
+	if err := s.db.First(&expert, req.ExpertID).Error; err != nil {
+		return nil, ErrExpertNotFound
+	}
+
+	if req.SlotID != nil {
+		var slot models.AvailableSlot
+		if err := s.db.Where("id = ? AND expert_id = ?", *req.SlotID, req.ExpertID).First(&slot).Error; err != nil {
+			return nil, ErrSlotUnavailable
+		}
+	}
+
+	if req.From != nil && req.Until != nil && !req.Until.After(*req.From) {
+		return nil, errors.New("until must be after from")
+	}
+
+	var active int64
+	if err := s.db.Model(&models.WaitlistEntry{}).
+		Where("user_id = ? AND expert_id = ? AND status IN (?)",
+			userID, req.ExpertID, []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+		Count(&active).Error; err != nil {
+		return nil, err
+	}
+	if active > 0 {
+		return nil, ErrAlreadyWaitlisted
+	}
+
+	entry := models.WaitlistEntry{
+		UserID:   userID,
+		ExpertID: req.ExpertID,
+		SlotID:   req.SlotID,
+		Status:   models.WaitlistStatusWaiting,
+	}
+	if req.From != nil {
+		from := req.From.UTC()
+		entry.WindowStart = &from
+	}
+	if req.Until != nil {
+		until := req.Until.UTC()
+		entry.WindowEnd = &until
+	}
+
+	if err := s.db.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *WaitlistService) GetEntries(userID uint) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	err := s.db.Where("user_id = ? AND status IN (?)",
+		userID, []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+		Order("created_at").
+		Find(&entries).Error
+	return entries, err
+}
+
+// LeaveWaitlist removes the user from the line. A slot they were being
+// offered passes to the next person.
+func (s *WaitlistService) LeaveWaitlist(entryID, userID uint) error {
+	var entry models.WaitlistEntry
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", entryID, userID).First(&entry).Error; err != nil {
+			return ErrWaitlistEntryNotFound
+		}
+
+		if err := tx.Model(&entry).Update("status", models.WaitlistStatusCancelled).Error; err != nil {
+			return err
+		}
+
+		var offers []models.WaitlistOffer
+		if err := tx.Where("entry_id = ? AND status = ?", entry.ID, models.OfferStatusPending).Find(&offers).Error; err != nil {
+			return err
+		}
+		for i := range offers {
+			if err := s.passOffer(tx, &offers[i], models.OfferStatusDeclined); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateSlotCache(s.redis, entry.ExpertID)
+	return nil
+}
+
+// GetOffers returns the user's open offers.
+func (s *WaitlistService) GetOffers(userID uint) ([]models.WaitlistOffer, error) {
+	var offers []models.WaitlistOffer
+	err := s.db.Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.OfferStatusPending, time.Now()).
+		Preload("Slot").
+		Order("expires_at").
+		Find(&offers).Error
+	return offers, err
+}
+
+// ClaimOffer books the offered slot for the user.
+func (s *WaitlistService) ClaimOffer(offerID, userID uint, req ClaimOfferRequest) (*models.Booking, error) {
+	var booking *models.Booking
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		offer, err := lockOffer(tx, offerID, userID)
+		if err != nil {
+			return err
+		}
+		if !offer.ExpiresAt.After(time.Now()) {
+			return ErrOfferExpired
+		}
+
+		var slot models.AvailableSlot
+		if err := tx.First(&slot, offer.SlotID).Error; err != nil {
+			return ErrSlotUnavailable
+		}
+
+		// A seat is held for the offer; free it so it can be reserved
+		if err := releaseSeat(tx, slot.ID); err != nil {
+			return err
+		}
+
+		booking, err = s.bookings.reserveSlot(tx, userID, CreateBookingRequest{
+			ExpertID:  slot.ExpertID,
+			StartTime: slot.StartTime,
+			EndTime:   slot.EndTime,
+			Notes:     req.Notes,
+			Format:    req.Format,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(offer).Updates(map[string]interface{}{
+			"status":     models.OfferStatusClaimed,
+			"booking_id": booking.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WaitlistEntry{}).Where("id = ?", offer.EntryID).
+			Update("status", models.WaitlistStatusBooked).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateSlotCache(s.redis, booking.ExpertID)
+
+	// Load relationships
+	s.db.Preload("User").Preload("Expert").Preload("Expert.User").First(booking, booking.ID)
+
+	return booking, nil
+}
+
+// DeclineOffer lets the slot go to the next person straight away. The user
+// stays on the waitlist.
+func (s *WaitlistService) DeclineOffer(offerID, userID uint) error {
+	var slotID uint
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		offer, err := lockOffer(tx, offerID, userID)
+		if err != nil {
+			return err
+		}
+		slotID = offer.SlotID
+		return s.passOffer(tx, offer, models.OfferStatusDeclined)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateSlotCacheFor(s.db, s.redis, slotID)
+	return nil
+}
+
+// ExpireOffers passes offers that weren't claimed in time to the next
+// person in line. Run by the worker.
+func (s *WaitlistService) ExpireOffers() (int, error) {
+	var expired []models.WaitlistOffer
+	if err := s.db.Where("status = ? AND expires_at <= ?", models.OfferStatusPending, time.Now()).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	for _, offer := range expired {
+		err := inTransaction(s.db, func(tx *gorm.DB) error {
+			var locked models.WaitlistOffer
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND status = ?", offer.ID, models.OfferStatusPending).
+				First(&locked).Error; err != nil {
+				// Claimed or declined in the meantime
+				return nil
+			}
+			return s.passOffer(tx, &locked, models.OfferStatusExpired)
+		})
+		if err != nil {
+			return 0, err
+		}
+		invalidateSlotCacheFor(s.db, s.redis, offer.SlotID)
+	}
+	return len(expired), nil
+}
+
+// SlotReleasedHook offers slots freed by cancellations, rejections and
+// expiries to the waitlist. It is registered with
+// BookingService.OnTransition after the hook that releases the slot.
+func (s *WaitlistService) SlotReleasedHook(tx *gorm.DB, t BookingTransition) error {
+	if !slotReleasingStatuses[t.To] || t.Booking.SlotID == nil {
+		return nil
+	}
+	return s.offerSlot(tx, *t.Booking.SlotID)
+}
+
+// RescheduleHook offers slots freed by moved bookings and declined
+// reschedules to the waitlist. It is registered with
+// BookingService.OnReschedule.
+func (s *WaitlistService) RescheduleHook(tx *gorm.DB, e BookingRescheduleEvent) error {
+	r := e.Reschedule
+	switch e.Event {
+	case RescheduleApplied:
+		if r.OldSlotID != nil && (r.NewSlotID == nil || *r.OldSlotID != *r.NewSlotID) {
+			return s.offerSlot(tx, *r.OldSlotID)
+		}
+	case RescheduleDeclined:
+		if r.NewSlotID != nil && (e.Booking.SlotID == nil || *e.Booking.SlotID != *r.NewSlotID) {
+			return s.offerSlot(tx, *r.NewSlotID)
+		}
+	}
+	return nil
+}
+
+// passOffer closes an offer, returns its user to the line and offers the
+// slot to the next person.
+func (s *WaitlistService) passOffer(tx *gorm.DB, offer *models.WaitlistOffer, status string) error {
+	if err := tx.Model(offer).Update("status", status).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.WaitlistEntry{}).
+		Where("id = ? AND status = ?", offer.EntryID, models.WaitlistStatusOffered).
+		Update("status", models.WaitlistStatusWaiting).Error; err != nil {
+		return err
+	}
+
+	if err := releaseSeat(tx, offer.SlotID); err != nil {
+		return err
+	}
+	return s.offerSlot(tx, offer.SlotID)
+}
+
+// offerSlot holds a free seat of a slot for the first matching waitlisted
+// user who hasn't been offered it yet and isn't already attending. With
+// nobody waiting the seat stays free.
+func (s *WaitlistService) offerSlot(tx *gorm.DB, slotID uint) error {
+	var slot models.AvailableSlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, slotID).Error; err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if slot.IsBooked || !slot.StartTime.After(now) {
+		return nil
+	}
+
+	// Offers must be claimable within the expert's minimum notice
+	settings, err := loadExpertSettings(tx, slot.ExpertID, s.bookings.policy.Scheduling)
+	if err != nil {
+		return err
+	}
+	deadline := slot.StartTime.Add(-time.Duration(settings.MinNoticeMinutes) * time.Minute)
+	if !deadline.After(now) {
+		return nil
+	}
+
+	var entry models.WaitlistEntry
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("expert_id = ? AND status = ?", slot.ExpertID, models.WaitlistStatusWaiting).
+		Where("slot_id IS NULL OR slot_id = ?", slot.ID).
+		Where("(window_start IS NULL OR window_start <= ?) AND (window_end IS NULL OR window_end >= ?)", slot.StartTime, slot.EndTime).
+		Where("NOT EXISTS (SELECT 1 FROM waitlist_offers WHERE waitlist_offers.entry_id = waitlist_entries.id AND waitlist_offers.slot_id = ?)", slot.ID).
+		Where("NOT EXISTS (SELECT 1 FROM bookings WHERE bookings.user_id = waitlist_entries.user_id AND bookings.slot_id = ? AND bookings.status IN ?)", slot.ID, activeBookingStatuses).
+		Order("created_at, id").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Offers never outlive the chance to book the slot
+	expiresAt := now.Add(s.offerTTL)
+	if expiresAt.After(deadline) {
+		expiresAt = deadline
+	}
+
+	if taken, err := takeSeat(tx, slot.ID); err != nil || !taken {
+		return err
+	}
+
+	offer := models.WaitlistOffer{
+		EntryID:   entry.ID,
+		UserID:    entry.UserID,
+		SlotID:    slot.ID,
+		ExpiresAt: expiresAt,
+		Status:    models.OfferStatusPending,
+	}
+	if err := tx.Omit("Slot").Create(&offer).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&entry).Update("status", models.WaitlistStatusOffered).Error; err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := tx.First(&user, entry.UserID).Error; err != nil {
+		return err
+	}
+	var expert models.Expert
+	if err := tx.Preload("User").First(&expert, slot.ExpertID).Error; err != nil {
+		return err
+	}
+
+	if err := createNotification(tx, entry.UserID, "Slot Available",
+		fmt.Sprintf("A slot with %s on %s is held for you until %s",
+			expert.User.Name, FormatInZone(slot.StartTime, user.Timezone), FormatInZone(expiresAt, user.Timezone)),
+		"waitlist"); err != nil {
+		return err
+	}
+
+	// The offer may still be rolled back with the transition that freed
+	// the slot
+	afterCommit(tx, func() {
+		go func() {
+			if err := s.emailService.SendWaitlistOffer(user.Email, expert.User.Name, slot.StartTime, expiresAt, user.Timezone); err != nil {
+				log.Printf("Failed to send waitlist offer email: %v", err)
+			}
+		}()
+	})
+	return nil
+}
+
+func lockOffer(tx *gorm.DB, offerID, userID uint) (*models.WaitlistOffer, error) {
+	var offer models.WaitlistOffer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ? AND status = ?", offerID, userID, models.OfferStatusPending).
+		First(&offer).Error; err != nil {
+		return nil, ErrOfferNotFound
+	}
+	return &offer, nil
+}
@@ -17,14 +17,20 @@ type Worker struct {
 	redis               *redis.Client
 	emailService        *services.EmailService
 	notificationService *services.NotificationService
+	bookingService      *services.BookingService
+	availabilityService *services.AvailabilityService
+	waitlistService     *services.WaitlistService
 }
 
-func NewWorker(db *gorm.DB, redis *redis.Client, emailService *services.EmailService, notificationService *services.NotificationService) *Worker {
+func NewWorker(db *gorm.DB, redis *redis.Client, emailService *services.EmailService, notificationService *services.NotificationService, bookingService *services.BookingService, availabilityService *services.AvailabilityService, waitlistService *services.WaitlistService) *Worker {
 	return &Worker{
 		db:                  db,
 		redis:               redis,
 		emailService:        emailService,
 		notificationService: notificationService,
+		bookingService:      bookingService,
+		availabilityService: availabilityService,
+		waitlistService:     waitlistService,
 	}
 }
 
@@ -40,7 +46,9 @@ func (w *Worker) Start() {
 		case <-ticker.C:
 			w.processReminders()
 			w.processExpiredBookings()
+			w.processExpiredOffers()
 			w.cleanupOldNotifications()
+			w.materializeAvailability()
 		}
 	}
 }
@@ -51,7 +59,7 @@ func (w *Worker) processReminders() {
 	var bookings []models.Booking
 
 	w.db.Where("start_time BETWEEN ? AND ? AND status = ?",
-		reminderTime, reminderTime.Add(10*time.Minute), "confirmed").
+		reminderTime, reminderTime.Add(10*time.Minute), models.BookingStatusConfirmed).
 		Preload("User").
 		Preload("Expert").
 		Preload("Expert.User").
@@ -62,7 +70,8 @@ func (w *Worker) processReminders() {
 		err := w.emailService.SendReminder(
 			booking.User.Email,
 			booking.Expert.User.Name,
-			booking.StartTime.Format("2006-01-02 15:04"),
+			booking.StartTime,
+			booking.User.Timezone,
 		)
 		if err != nil {
 			log.Printf("Failed to send reminder email: %v", err)
@@ -91,21 +100,36 @@ func (w *Worker) processReminders() {
 func (w *Worker) processExpiredBookings() {
 	// Mark bookings as missed if they're past their time and still pending
 	var expiredBookings []models.Booking
-	w.db.Where("end_time < ? AND status = ?", time.Now(), "pending").Find(&expiredBookings)
+	w.db.Where("end_time < ? AND status = ?", time.Now(), models.BookingStatusPending).Find(&expiredBookings)
 
 	for _, booking := range expiredBookings {
-		w.db.Model(&booking).Update("status", "missed")
-
-		// Free up the slot
-		w.db.Model(&models.AvailableSlot{}).Where(
-			"expert_id = ? AND start_time <= ? AND end_time >= ?",
-			booking.ExpertID, booking.StartTime, booking.EndTime,
-		).Update("is_booked", false)
+		// Slot release and notifications are handled by the transition hooks
+		if _, err := w.bookingService.TransitionBooking(booking.ID, services.SystemActor(), models.BookingStatusMissed, ""); err != nil {
+			log.Printf("Failed to expire booking %d: %v", booking.ID, err)
+		}
 	}
 
 	log.Printf("Processed %d expired bookings", len(expiredBookings))
 }
 
+func (w *Worker) processExpiredOffers() {
+	// Pass unclaimed waitlist offers on to the next person in line
+	count, err := w.waitlistService.ExpireOffers()
+	if err != nil {
+		log.Printf("Failed to expire waitlist offers: %v", err)
+		return
+	}
+
+	log.Printf("Processed %d expired waitlist offers", count)
+}
+
+func (w *Worker) materializeAvailability() {
+	// Roll recurring availability forward so the horizon stays filled
+	if err := w.availabilityService.MaterializeAll(); err != nil {
+		log.Printf("Failed to materialize availability: %v", err)
+	}
+}
+
 func (w *Worker) cleanupOldNotifications() {
 	// Delete notifications older than 30 days
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
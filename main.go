@@ -2,6 +2,7 @@
 package main
 
 import (
+	"consultation-booking/internal/auth"
 	"consultation-booking/internal/config"
 	"consultation-booking/internal/database"
 	"consultation-booking/internal/middleware"
@@ -11,6 +12,7 @@ import (
 	"consultation-booking/internal/worker"
 	"log"
 	"net/http"
+	_ "time/tzdata" // the alpine image ships without zoneinfo
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -25,6 +27,12 @@ func main() {
 	// Initialize configuration
 	cfg := config.Load()
 
+	// Initialize token issuer/verifier
+	tokens, err := auth.NewTokenManager(cfg.JWT)
+	if err != nil {
+		log.Fatal("Failed to initialize JWT keys:", err)
+	}
+
 	// Initialize database
 	db, err := database.Connect(cfg.DatabaseURL)
 	if err != nil {
@@ -35,10 +43,21 @@ func main() {
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Expert{},
+		&models.ExpertLanguage{},
 		&models.Booking{},
+		&models.BookingSeries{},
 		&models.Notification{},
 		&models.Feedback{},
 		&models.AvailableSlot{},
+		&models.AvailabilityRule{},
+		&models.AvailabilityOverride{},
+		&models.RecoveryCode{},
+		&models.BookingReschedule{},
+		&models.CancellationPolicy{},
+		&models.ExpertSettings{},
+		&models.SessionType{},
+		&models.WaitlistEntry{},
+		&models.WaitlistOffer{},
 	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -47,14 +66,22 @@ func main() {
 	redisClient := database.InitRedis(cfg.RedisURL)
 
 	// Initialize services
-	userService := services.NewUserService(db, redisClient)
-	expertService := services.NewExpertService(db, redisClient)
-	bookingService := services.NewBookingService(db, redisClient)
+	emailService := services.NewEmailService(cfg.SMTPConfig, cfg.AppURL)
+	userService := services.NewUserService(db, redisClient, tokens, emailService)
+	expertService := services.NewExpertService(db, redisClient, cfg.Booking)
+	bookingService := services.NewBookingService(db, redisClient, cfg.Booking)
 	notificationService := services.NewNotificationService(db, redisClient)
-	emailService := services.NewEmailService(cfg.SMTPConfig)
+	availabilityService := services.NewAvailabilityService(db, redisClient)
+	waitlistService := services.NewWaitlistService(db, redisClient, emailService, bookingService, cfg.Booking.WaitlistOfferTTL)
+
+	// Booking lifecycle hooks
+	bookingService.OnTransition(notificationService.BookingTransitionHook)
+	bookingService.OnReschedule(notificationService.BookingRescheduleHook)
+	bookingService.OnTransition(waitlistService.SlotReleasedHook)
+	bookingService.OnReschedule(waitlistService.RescheduleHook)
 
 	// Initialize worker
-	workerService := worker.NewWorker(db, redisClient, emailService, notificationService)
+	workerService := worker.NewWorker(db, redisClient, emailService, notificationService, bookingService, availabilityService, waitlistService)
 	go workerService.Start()
 
 	// Initialize Gin router
@@ -66,7 +93,7 @@ func main() {
 	router.Use(middleware.LoggingMiddleware())
 
 	// Setup routes
-	routes.SetupRoutes(router, userService, expertService, bookingService, notificationService)
+	routes.SetupRoutes(router, userService, expertService, bookingService, notificationService, availabilityService, waitlistService, redisClient, tokens)
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Port)